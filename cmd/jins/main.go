@@ -1,29 +1,122 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/bashi/json-tools"
 )
 
+var lazy = flag.Bool("lazy", false, "Decode the document on demand (default for files over 1GB)")
+var commands = flag.String("c", "", "Run these commands, separated by ';', instead of prompting")
+var scriptFile = flag.String("f", "", "Run the commands in this file (- for stdin) instead of prompting")
+var jsonl = flag.Bool("jsonl", false, "Open each line of the files as a document (default for .jsonl and .ndjson files)")
+
+// Files at least this large are opened lazily.
+const lazyThreshold = 1 << 30
+
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Fprintf(os.Stderr, "Must specify JSON file\n")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Must specify JSON files\n")
+		os.Exit(1)
+	}
+	if *commands != "" && *scriptFile != "" {
+		fmt.Fprintf(os.Stderr, "Cannot use both -c and -f\n")
 		os.Exit(1)
 	}
 
-	f, err := os.Open(os.Args[1])
-	defer f.Close()
-	if err != nil {
-		panic(err)
+	var i *jsontools.Inspector
+	for _, name := range flag.Args() {
+		other, err := open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if i == nil {
+			i = other
+		} else {
+			i.Append(other)
+		}
 	}
-	i, err := jsontools.NewInspector(f)
-	if err != nil {
-		panic(err)
+	defer i.Close()
+	if *commands != "" || *scriptFile != "" {
+		runScript(i)
+		return
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		i.HistoryFile = filepath.Join(home, ".jins_history")
 	}
 	if err := i.Repl(); err != nil && err != io.EOF {
 		panic(err)
 	}
 }
+
+// open returns an Inspector for the documents in the file name.
+func open(name string) (*jsontools.Inspector, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	if *jsonl || ext == ".jsonl" || ext == ".ndjson" {
+		defer f.Close()
+		return jsontools.NewRecordsInspector(f, name)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	isLazy := *lazy || info.Size() >= lazyThreshold
+	var i *jsontools.Inspector
+	mapped, mapErr := jsontools.MapFile(f)
+	if mapErr == nil {
+		// The Inspector releases the mapping when it is closed.
+		f.Close()
+		if isLazy {
+			i, err = mapped.NewLazyInspector()
+		} else {
+			i, err = mapped.NewInspector()
+		}
+		if err != nil {
+			mapped.Close()
+		}
+	} else if isLazy {
+		// A lazy document keeps reading f.
+		i, err = jsontools.NewLazyInspector(f, info.Size())
+	} else {
+		i, err = jsontools.NewInspector(f)
+		f.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	i.File = name
+	return i, nil
+}
+
+func runScript(i *jsontools.Inspector) {
+	var script io.Reader = strings.NewReader(*commands)
+	switch *scriptFile {
+	case "":
+	case "-":
+		script = os.Stdin
+	default:
+		f, err := os.Open(*scriptFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		script = f
+	}
+	if err := i.RunScript(script); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/bashi/go-repl"
 	"github.com/bashi/json-tools"
@@ -13,23 +14,141 @@ import (
 
 var _ = pp.Println
 
+var buildIndex = flag.Bool("build-index", false, "Write the index file and exit")
+var useIndex = flag.Bool("use-index", false, "Load the index file, rebuilding it if it is missing or out of date")
+var indexFile = flag.String("index", "", "Index file (default <path>.idx for a single file or directory)")
+var fuzzy = flag.Bool("fuzzy", false, "Also match plain terms with typos")
+var limit = flag.Int("limit", 50, "Number of results per page; enter + for the next page")
+var pathFormat = flag.String("path", "", "Show paths as pointer, jsonpath or jq")
+var kind = flag.String("kind", "", "Comma-separated kinds to match: member, string, number, literal, keys or values")
+
+func sameFiles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if a[n] != b[n] {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultIndexName returns the name of the index file for args, which is
+// only defined for a single existing file or directory. Several paths or a
+// pattern would otherwise share the index of whatever came first.
+func defaultIndexName(args []string) string {
+	if len(args) != 1 {
+		return ""
+	}
+	if _, err := os.Stat(args[0]); err != nil {
+		return ""
+	}
+	return filepath.Clean(args[0]) + ".idx"
+}
+
 func main() {
 	flag.Parse()
-	file, err := os.Open(flag.Arg(0))
+	if flag.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Must specify JSON files or directories\n")
+		os.Exit(1)
+	}
+	names, err := jsontools.ExpandPaths(flag.Args())
 	if err != nil {
 		panic(err)
 	}
+	var kinds []jsontools.IndexKind
+	if *kind != "" {
+		if kinds, err = jsontools.ParseIndexKinds(*kind); err != nil {
+			panic(err)
+		}
+	}
+	var format jsontools.PathFormat
+	if *pathFormat != "" {
+		if format, err = jsontools.ParsePathFormat(*pathFormat); err != nil {
+			panic(err)
+		}
+	}
+	indexName := *indexFile
+	if indexName == "" && (*buildIndex || *useIndex) {
+		if indexName = defaultIndexName(flag.Args()); indexName == "" {
+			fmt.Fprintf(os.Stderr, "Must specify -index for several paths or a pattern\n")
+			os.Exit(1)
+		}
+	}
 
-	indexer := jsontools.NewIndexer(file)
-	index, err := indexer.CreateIndex()
-	if err != nil {
-		panic(err)
+	var index *jsontools.Index
+	if *useIndex && !*buildIndex {
+		index, err = jsontools.OpenIndex(indexName)
+		if err != nil && !os.IsNotExist(err) && err != jsontools.ErrStaleIndex {
+			panic(err)
+		}
+		if index != nil && !sameFiles(index.Files(), names) {
+			index.Close()
+			index = nil
+		}
+	}
+	if index == nil {
+		index, err = jsontools.IndexFiles(names)
+		if err != nil {
+			panic(err)
+		}
+		if *buildIndex || *useIndex {
+			if err := index.Save(indexName); err != nil {
+				panic(err)
+			}
+		}
+	}
+	defer index.Close()
+	if *buildIndex {
+		return
 	}
 	//pp.Println(index)
+	opts := &jsontools.SearchOptions{
+		Kinds: kinds,
+		Fuzzy: *fuzzy,
+		Limit: *limit,
+	}
+	query := ""
 	err = repl.Run(func(line string) error {
-		results := index.Match(line)
-		for _, r := range results {
-			fmt.Println(r)
+		if line == "+" {
+			opts.Offset += opts.Limit
+		} else {
+			query = line
+			opts.Offset = 0
+		}
+		if query == "" {
+			return nil
+		}
+		result, err := index.Search(query, opts)
+		if err != nil {
+			fmt.Println(err)
+			return nil
+		}
+		// Results are ranked across files, so group them by file, keeping
+		// the files in the order of their best result.
+		var files []string
+		byFile := make(map[string][]*jsontools.IndexEntry)
+		for _, r := range result.Entries {
+			if byFile[r.File] == nil {
+				files = append(files, r.File)
+			}
+			byFile[r.File] = append(byFile[r.File], r)
+		}
+		for _, file := range files {
+			if file != "" {
+				fmt.Printf("%s:\n", file)
+			}
+			for _, r := range byFile[file] {
+				if *pathFormat != "" {
+					fmt.Printf("  %s: %s: %s (%s)\n", r.Pos.String(), r.FormatPath(format), r.Ident, r.Kind)
+				} else {
+					fmt.Printf("  %s (%s)\n", r, r.Kind)
+				}
+			}
+		}
+		if shown := opts.Offset + len(result.Entries); shown < result.Total {
+			fmt.Printf("-- %d-%d of %d, + for more --\n", opts.Offset+1, shown, result.Total)
 		}
 		return nil
 	})
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultIndexName(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.json")
+	assert.Nil(t, os.WriteFile(name, []byte(`{}`), 0644))
+	assert.Equal(t, name+".idx", defaultIndexName([]string{name}))
+	assert.Equal(t, dir+".idx", defaultIndexName([]string{dir + "/"}))
+	assert.Equal(t, "", defaultIndexName([]string{filepath.Join(dir, "*.json")}))
+	assert.Equal(t, "", defaultIndexName([]string{name, dir}))
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bashi/json-tools"
+)
+
+var pkg = flag.String("package", "main", "Package name")
+var typeName = flag.String("type", "Root", "Name of the type for the document root")
+
+func main() {
+	flag.Parse()
+	g := jsontools.NewGoGenerator()
+	g.Package = *pkg
+	g.TypeName = *typeName
+	if err := jsontools.ReadFiles(flag.Args(), g.Add); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := g.Generate(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bashi/json-tools"
+)
+
+var typeName = flag.String("type", "Root", "Name of the type for the document root")
+var zod = flag.Bool("zod", false, "Also emit Zod schemas")
+
+func main() {
+	flag.Parse()
+	g := jsontools.NewTypeScriptGenerator()
+	g.TypeName = *typeName
+	g.Zod = *zod
+	if err := jsontools.ReadFiles(flag.Args(), g.Add); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := g.Generate(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bashi/json-tools"
+)
+
+type pathList []string
+
+func (l *pathList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *pathList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+var nocolor = flag.Bool("nocolor", false, "No color")
+var format = flag.String("format", "text", "Output format: text, unified or json")
+var ignoreOrder = flag.Bool("ignore-order", false, "Ignore the order of array elements")
+var tolerance = flag.Float64("tolerance", 0, "Treat numbers within this distance as equal")
+var ignorePaths pathList
+
+// fail reports err and exits with status 2, which diff uses for trouble
+// as opposed to differences.
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
+
+func open(name string) *os.File {
+	f, err := os.Open(name)
+	if err != nil {
+		fail(err)
+	}
+	return f
+}
+
+func main() {
+	flag.Var(&ignorePaths, "ignore", "Path to ignore, like .a.b or /a/b (repeatable)")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: jsondiff [options] a.json b.json\n")
+		os.Exit(2)
+	}
+
+	fa := open(flag.Arg(0))
+	defer fa.Close()
+	a, err := jsontools.Decode(fa)
+	if err != nil {
+		fail(fmt.Errorf("%s: %s", flag.Arg(0), err))
+	}
+	fb := open(flag.Arg(1))
+	defer fb.Close()
+	b, err := jsontools.Decode(fb)
+	if err != nil {
+		fail(fmt.Errorf("%s: %s", flag.Arg(1), err))
+	}
+	diff, err := jsontools.CompareDocuments(a, b, &jsontools.DiffOptions{
+		IgnoreArrayOrder: *ignoreOrder,
+		IgnorePaths:      ignorePaths,
+		Tolerance:        *tolerance,
+	})
+	if err != nil {
+		fail(err)
+	}
+
+	formatter := jsontools.NewDiffFormatter(diff, os.Stdout)
+	if !*nocolor {
+		formatter.EnableColor()
+	}
+	switch *format {
+	case "text":
+		err = formatter.WriteText()
+	case "unified":
+		err = formatter.WriteUnified(flag.Arg(0), flag.Arg(1))
+	case "json":
+		err = formatter.WriteJSON()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format: %s\n", *format)
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+	if len(diff.Entries) > 0 {
+		os.Exit(1)
+	}
+}
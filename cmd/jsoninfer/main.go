@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bashi/json-tools"
+)
+
+var maxEnum = flag.Int("max-enum", 10, "Largest number of distinct strings reported as an enum")
+var nocolor = flag.Bool("nocolor", false, "No color")
+var indent = flag.Int("indent", 2, "Indent width")
+
+func main() {
+	flag.Parse()
+	inferrer := jsontools.NewSchemaInferrer()
+	inferrer.MaxEnum = *maxEnum
+	if err := jsontools.ReadFiles(flag.Args(), inferrer.Add); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var buf bytes.Buffer
+	if err := inferrer.Schema().Encode(&buf, 0); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	formatter := jsontools.NewFormatter(&buf, os.Stdout)
+	formatter.SetIndentWidth(*indent)
+	if !*nocolor {
+		formatter.EnableColor()
+	}
+	if err := formatter.Dump(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
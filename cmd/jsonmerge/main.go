@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bashi/json-tools"
+)
+
+var arrays = flag.String("arrays", "replace", "Array strategy: replace, concat or merge")
+var key = flag.String("key", "id", "Member identifying array elements with -arrays=merge")
+var patch = flag.Bool("patch", false, "Apply later files as JSON Merge Patches (null deletes)")
+var origins = flag.Bool("origins", false, "Print each value with the file it came from")
+var nocolor = flag.Bool("nocolor", false, "No color")
+var indent = flag.Int("indent", 2, "Indent width")
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: jsonmerge [options] base.json override.json...\n")
+		os.Exit(2)
+	}
+
+	opts := &jsontools.MergeOptions{
+		Key:         *key,
+		NullDeletes: *patch,
+	}
+	switch *arrays {
+	case "replace":
+		opts.Arrays = jsontools.ArrayReplace
+	case "concat":
+		opts.Arrays = jsontools.ArrayConcat
+	case "merge":
+		opts.Arrays = jsontools.ArrayMergeByKey
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown array strategy: %s\n", *arrays)
+		os.Exit(2)
+	}
+
+	merger := jsontools.NewMerger(opts)
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			panic(err)
+		}
+		doc, err := jsontools.Decode(f)
+		f.Close()
+		if err != nil {
+			panic(err)
+		}
+		merger.Add(name, doc)
+	}
+
+	if *origins {
+		if err := merger.WriteOrigins(os.Stdout); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := merger.Result().Encode(&buf, 0); err != nil {
+		panic(err)
+	}
+	formatter := jsontools.NewFormatter(&buf, os.Stdout)
+	formatter.SetIndentWidth(*indent)
+	if !*nocolor {
+		formatter.EnableColor()
+	}
+	if err := formatter.Dump(); err != nil {
+		panic(err)
+	}
+}
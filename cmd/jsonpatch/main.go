@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bashi/json-tools"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs jsonpatch with the command line arguments args and returns the
+// exit status.
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("jsonpatch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	create := flags.Bool("create", false, "Print a patch that turns the first file into the second")
+	nocolor := flags.Bool("nocolor", false, "No color")
+	indent := flags.Int("indent", 2, "Indent width")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintf(stderr, "Usage: jsonpatch doc.json patch.json\n")
+		fmt.Fprintf(stderr, "       jsonpatch -create a.json b.json\n")
+		return 2
+	}
+	if err := patchFiles(flags.Arg(0), flags.Arg(1), *create, !*nocolor, *indent, stdout); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// patchFiles writes the document in docName patched by patchName, or with
+// create the patch which turns docName into patchName.
+func patchFiles(docName, patchName string, create, colors bool, indent int, w io.Writer) error {
+	fd, err := os.Open(docName)
+	if err != nil {
+		return err
+	}
+	doc, err := jsontools.Decode(fd)
+	fd.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %s", docName, err)
+	}
+	fp, err := os.Open(patchName)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	if create {
+		target, err := jsontools.Decode(fp)
+		if err != nil {
+			return fmt.Errorf("%s: %s", patchName, err)
+		}
+		var buf bytes.Buffer
+		if err := jsontools.CreatePatch(doc, target).Encode(&buf); err != nil {
+			return err
+		}
+		formatter := jsontools.NewFormatter(&buf, w)
+		formatter.SetIndentWidth(indent)
+		if colors {
+			formatter.EnableColor()
+		}
+		return formatter.Dump()
+	}
+
+	patch, err := jsontools.ParsePatch(fp)
+	if err != nil {
+		return fmt.Errorf("%s: %s", patchName, err)
+	}
+	if err := doc.ApplyPatch(patch); err != nil {
+		return err
+	}
+	// The patched document may be any value, which Formatter can't
+	// parse, so write it out directly.
+	if colors {
+		return doc.EncodeColor(w, indent)
+	}
+	return doc.Encode(w, indent)
+}
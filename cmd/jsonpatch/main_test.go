@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"doc.json":    `{"a": 1, "b": [true]}`,
+		"patch.json":  `[{"op": "add", "path": "/b/-", "value": null}]`,
+		"scalar.json": `[{"op": "replace", "path": "", "value": "x"}]`,
+		"bad.json":    `[{"op": "remove", "path": "/c"}]`,
+	})
+	jsonpatch := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		for n, arg := range args {
+			if filepath.Ext(arg) == ".json" {
+				args[n] = filepath.Join(dir, arg)
+			}
+		}
+		status := run(append([]string{"-nocolor"}, args...), &stdout, &stderr)
+		return status, stdout.String(), stderr.String()
+	}
+
+	status, out, _ := jsonpatch("doc.json", "patch.json")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "{\n  \"a\": 1,\n  \"b\": [\n    true,\n    null\n  ]\n}\n", out)
+
+	status, out, _ = jsonpatch("doc.json", "scalar.json")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "\"x\"\n", out)
+
+	status, _, errors := jsonpatch("doc.json", "bad.json")
+	assert.Equal(t, 1, status)
+	assert.NotEmpty(t, errors)
+	status, _, errors = jsonpatch("doc.json", "missing.json")
+	assert.Equal(t, 1, status)
+	assert.Contains(t, errors, "missing.json")
+	status, _, _ = jsonpatch("doc.json")
+	assert.Equal(t, 2, status)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bashi/json-tools"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: jsonschema validate [-format text|json] schema.json doc.json...\n")
+	os.Exit(2)
+}
+
+func open(name string) *os.File {
+	f, err := os.Open(name)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	format := flags.String("format", "text", "Output format: text or json")
+	flags.Parse(args)
+	if flags.NArg() < 2 || (*format != "text" && *format != "json") {
+		usage()
+	}
+
+	f := open(flags.Arg(0))
+	schema, err := jsontools.NewSchema(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", flags.Arg(0), err)
+		return 2
+	}
+
+	status := 0
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for _, name := range flags.Args()[1:] {
+		f := open(name)
+		doc, err := jsontools.DecodeWithPositions(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			return 2
+		}
+		errs := schema.Validate(doc)
+		if len(errs) > 0 {
+			status = 1
+		}
+		for _, e := range errs {
+			if *format == "text" {
+				fmt.Printf("%s:%v\n", name, e)
+				continue
+			}
+			if buf.Len() > 1 {
+				buf.WriteString(",")
+			}
+			file, _ := json.Marshal(name)
+			fmt.Fprintf(&buf, `{"file":%s,"error":`, file)
+			b, _ := e.MarshalJSON()
+			buf.Write(b)
+			buf.WriteString("}")
+		}
+	}
+	buf.WriteString("]")
+
+	if *format == "json" {
+		formatter := jsontools.NewFormatter(&buf, os.Stdout)
+		if err := formatter.Dump(); err != nil {
+			panic(err)
+		}
+	}
+	return status
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "validate":
+		os.Exit(validate(os.Args[2:]))
+	default:
+		usage()
+	}
+}
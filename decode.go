@@ -3,21 +3,24 @@ package jsontools
 import (
 	"fmt"
 	"io"
+	"os"
 	"runtime"
 	"strconv"
+	"strings"
 )
 
 type symbolTable map[uint]string
 
 type symtabMaker struct {
-	nextId   uint
 	symtab   symbolTable
 	inverted map[string]uint
+	// next is the id of the next new symbol. Ids aren't reused, even
+	// when a lazyDocument drops symbols from the table.
+	next uint
 }
 
 func newSymtabMaker() *symtabMaker {
 	return &symtabMaker{
-		nextId:   0,
 		symtab:   make(symbolTable),
 		inverted: make(map[string]uint),
 	}
@@ -27,8 +30,8 @@ func (m *symtabMaker) getId(s string) uint {
 	if id, ok := m.inverted[s]; ok {
 		return id
 	}
-	id := m.nextId
-	m.nextId += 1
+	id := m.next
+	m.next++
 	m.inverted[s] = id
 	m.symtab[id] = s
 	return id
@@ -40,12 +43,34 @@ type jsonValue interface {
 
 type objectValue struct {
 	props map[uint]jsonValue
+	// keys holds the member names in the order they appeared.
+	keys []uint
 }
 
 func (v *objectValue) ToString() string {
 	return "[Object]"
 }
 
+func (v *objectValue) set(id uint, value jsonValue) {
+	if _, ok := v.props[id]; !ok {
+		v.keys = append(v.keys, id)
+	}
+	v.props[id] = value
+}
+
+func (v *objectValue) remove(id uint) {
+	if _, ok := v.props[id]; !ok {
+		return
+	}
+	delete(v.props, id)
+	for i, k := range v.keys {
+		if k == id {
+			v.keys = append(v.keys[:i], v.keys[i+1:]...)
+			break
+		}
+	}
+}
+
 type arrayValue struct {
 	elems []jsonValue
 }
@@ -64,6 +89,20 @@ func (v *stringValue) ToString() string {
 
 type numberValue struct {
 	value float64
+	// integer is true if the number was written without a fraction or
+	// exponent.
+	integer bool
+	// raw is the number as it was written, or empty if it was computed.
+	raw string
+}
+
+// jsonText returns the JSON text of v, which is the text it was decoded
+// from if it has any.
+func (v *numberValue) jsonText() string {
+	if v.raw != "" {
+		return v.raw
+	}
+	return formatNumber(v.value)
 }
 
 func (v *numberValue) ToString() string {
@@ -85,10 +124,21 @@ type decoderClient struct {
 	numObjects    int64
 	numArrays     int64
 	numPrimitives int64
+	parser        *Parser
+	// positions is nil unless positions are requested.
+	positions map[jsonValue]ParserPosition
+	// start is where the document starts.
+	start ParserPosition
 }
 
 func (c *decoderClient) push(v jsonValue) {
+	if len(c.stack) == 0 {
+		c.start = c.parser.TokenPos()
+	}
 	c.stack = append(c.stack, v)
+	if c.positions != nil {
+		c.positions[v] = c.parser.TokenPos()
+	}
 }
 
 func (c *decoderClient) pop() jsonValue {
@@ -137,7 +187,7 @@ func (c *decoderClient) EndMember(next HasNext) {
 	obj := c.currentObject()
 	name := c.memberStack[len(c.memberStack)-1]
 	symid := c.symtabMaker.getId(name)
-	obj.props[symid] = v
+	obj.set(symid, v)
 }
 
 func (c *decoderClient) StartValue() {
@@ -158,7 +208,11 @@ func (c *decoderClient) StringValue(s string) {
 
 func (c *decoderClient) NumberValue(s string) {
 	n, _ := strconv.ParseFloat(s, 64)
-	c.push(&numberValue{n})
+	c.push(&numberValue{
+		value:   n,
+		integer: !strings.ContainsAny(s, ".eE"),
+		raw:     s,
+	})
 	c.numPrimitives += 1
 }
 
@@ -173,28 +227,225 @@ type decodeResult struct {
 	numObjects    int64
 	numArrays     int64
 	numPrimitives int64
+	// positions maps values to where they start in the source. It is nil
+	// unless the document was decoded by DecodeWithPositions.
+	positions map[jsonValue]ParserPosition
+	// inverted is built on demand when the document is modified.
+	inverted map[string]uint
+	// resolveLazy decodes lazyValues. It is nil unless the document is
+	// decoded on demand.
+	resolveLazy func(jsonValue) (jsonValue, error)
+	// maker allocates the ids of new symbols when a decoder keeps adding
+	// to the table, as for a lazyDocument, and is nil otherwise.
+	maker *symtabMaker
 }
 
-func Decode(r io.Reader) (*decodeResult, error) {
-	c := &decoderClient{
-		symtabMaker: newSymtabMaker(),
+func (r *decodeResult) str(id uint) string {
+	return r.symtab[id]
+}
+
+// text returns the symbol id with its escape sequences resolved, for
+// comparing strings which may be written differently.
+func (r *decodeResult) text(id uint) string {
+	return unquoteRaw(r.symtab[id])
+}
+
+// value returns v, decoding it first if it is a lazyValue.
+func (r *decodeResult) value(v jsonValue) (jsonValue, error) {
+	if r.resolveLazy == nil {
+		return v, nil
 	}
-	parser := NewParser(r, c)
-	err := parser.Parse()
-	if err != nil {
-		return nil, err
+	return r.resolveLazy(v)
+}
+
+func (r *decodeResult) invert() {
+	if r.inverted != nil {
+		return
 	}
+	r.inverted = make(map[string]uint, len(r.symtab))
+	for id, s := range r.symtab {
+		r.inverted[s] = id
+	}
+}
+
+// lookup returns the symbol id for s, if s appears in the document.
+func (r *decodeResult) lookup(s string) (uint, bool) {
+	r.invert()
+	id, ok := r.inverted[s]
+	return id, ok
+}
+
+// intern returns the symbol id for s, adding s to the symbol table if
+// needed.
+func (r *decodeResult) intern(s string) uint {
+	if r.maker != nil {
+		return r.maker.getId(s)
+	}
+	if id, ok := r.lookup(s); ok {
+		return id
+	}
+	id := uint(len(r.symtab))
+	r.symtab[id] = s
+	r.inverted[s] = id
+	return id
+}
+
+// importValue returns a deep copy of v, which belongs to src, with its
+// strings interned in r.
+func (r *decodeResult) importValue(src *decodeResult, v jsonValue) jsonValue {
+	switch value := v.(type) {
+	case *objectValue:
+		obj := &objectValue{
+			props: make(map[uint]jsonValue, len(value.keys)),
+		}
+		for _, id := range value.keys {
+			obj.set(r.intern(src.str(id)), r.importValue(src, value.props[id]))
+		}
+		return obj
+	case *arrayValue:
+		arr := &arrayValue{
+			elems: make([]jsonValue, len(value.elems)),
+		}
+		for i, e := range value.elems {
+			arr.elems[i] = r.importValue(src, e)
+		}
+		return arr
+	case *stringValue:
+		return &stringValue{r.intern(src.str(value.id))}
+	case *numberValue:
+		return &numberValue{value.value, value.integer, value.raw}
+	case *literalValue:
+		return &literalValue{value.value}
+	}
+	return v
+}
+
+// Decode decodes the document in r.
+func Decode(r io.Reader) (*decodeResult, error) {
+	return decode(r, false)
+}
+
+// DecodeWithPositions is like Decode but also records the source
+// position of every value.
+func DecodeWithPositions(r io.Reader) (*decodeResult, error) {
+	return decode(r, true)
+}
+
+// result returns the document the client decoded.
+func (c *decoderClient) result() (*decodeResult, error) {
 	if len(c.stack) != 1 {
 		return nil, fmt.Errorf("Internal logic error: %d", len(c.stack))
 	}
-	result := &decodeResult{
+	return &decodeResult{
 		toplevel:      c.pop(),
 		symtab:        c.symtabMaker.symtab,
 		numObjects:    c.numObjects,
 		numArrays:     c.numArrays,
 		numPrimitives: c.numPrimitives,
+		positions:     c.positions,
+	}, nil
+}
+
+func decode(r io.Reader, withPositions bool) (*decodeResult, error) {
+	c := newDecoderClient(withPositions)
+	return c.decode(NewParser(r, c))
+}
+
+func newDecoderClient(withPositions bool) *decoderClient {
+	c := &decoderClient{
+		symtabMaker: newSymtabMaker(),
+	}
+	if withPositions {
+		c.positions = make(map[jsonValue]ParserPosition)
+	}
+	return c
+}
+
+// decode decodes the document parser reads, reporting it to c.
+func (c *decoderClient) decode(parser *Parser) (*decodeResult, error) {
+	c.parser = parser
+	err := parser.Parse()
+	if err != nil {
+		return nil, err
 	}
-	c = nil
+	result, err := c.result()
+	if err != nil {
+		return nil, err
+	}
+	c.parser = nil
 	runtime.GC()
 	return result, nil
 }
+
+// DocumentReader decodes a sequence of documents from a single reader,
+// such as a JSON Lines stream.
+type DocumentReader struct {
+	parser *Parser
+	client *decoderClient
+}
+
+func NewDocumentReader(r io.Reader) *DocumentReader {
+	c := &decoderClient{}
+	parser := NewParser(r, c)
+	c.parser = parser
+	return &DocumentReader{
+		parser: parser,
+		client: c,
+	}
+}
+
+// Next returns the next document, or io.EOF when there are no more.
+func (d *DocumentReader) Next() (*decodeResult, error) {
+	*d.client = decoderClient{
+		symtabMaker: newSymtabMaker(),
+		parser:      d.parser,
+	}
+	if err := d.parser.Parse(); err != nil {
+		return nil, err
+	}
+	if len(d.client.stack) == 0 {
+		return nil, io.EOF
+	}
+	return d.client.result()
+}
+
+// Pos returns where the document last returned by Next starts.
+func (d *DocumentReader) Pos() ParserPosition {
+	return d.client.start
+}
+
+// ReadDocuments calls add with every document in r, which may hold a
+// single document or a JSON Lines stream.
+func ReadDocuments(r io.Reader, add func(*decodeResult)) error {
+	reader := NewDocumentReader(r)
+	for {
+		doc, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		add(doc)
+	}
+}
+
+// ReadFiles is like ReadDocuments for each of the named files, or for
+// standard input if names is empty.
+func ReadFiles(names []string, add func(*decodeResult)) error {
+	if len(names) == 0 {
+		return ReadDocuments(os.Stdin, add)
+	}
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = ReadDocuments(f, add)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
@@ -12,26 +12,38 @@ var _ = fmt.Println
 
 func TestDecode(t *testing.T) {
 	r := strings.NewReader(`{"a": "foo", "b": [1, 2, 3], "c": {"x": "moge", "y": false, "z": 3.14}}`)
+	result, err := Decode(r)
+	assert.Nil(t, err)
+	id := func(s string) uint {
+		for id, sym := range result.symtab {
+			if sym == s {
+				return id
+			}
+		}
+		t.Fatalf("no symbol %q", s)
+		return 0
+	}
 	expected := &objectValue{
-		props: map[string]jsonValue{
-			"a": &stringValue{"foo"},
-			"b": &arrayValue{
+		props: map[uint]jsonValue{
+			id("a"): &stringValue{id("foo")},
+			id("b"): &arrayValue{
 				elems: []jsonValue{
-					&numberValue{1},
-					&numberValue{2},
-					&numberValue{3},
+					&numberValue{value: 1, integer: true, raw: "1"},
+					&numberValue{value: 2, integer: true, raw: "2"},
+					&numberValue{value: 3, integer: true, raw: "3"},
 				},
 			},
-			"c": &objectValue{
-				props: map[string]jsonValue{
-					"x": &stringValue{"moge"},
-					"y": &literalValue{False},
-					"z": &numberValue{3.14},
+			id("c"): &objectValue{
+				props: map[uint]jsonValue{
+					id("x"): &stringValue{id("moge")},
+					id("y"): &literalValue{False},
+					id("z"): &numberValue{value: 3.14, raw: "3.14"},
 				},
+				keys: []uint{id("x"), id("y"), id("z")},
 			},
 		},
+		keys: []uint{id("a"), id("b"), id("c")},
 	}
-	value, err := Decode(r)
-	assert.Nil(t, err)
-	assert.EqualValues(t, expected, value)
+	assert.EqualValues(t, expected, result.toplevel)
+	assert.Equal(t, 8, len(result.symtab))
 }
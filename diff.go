@@ -0,0 +1,427 @@
+package jsontools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+type DiffKind int
+
+const (
+	DiffAdded DiffKind = iota
+	DiffRemoved
+	DiffChanged
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+// DiffEntry is a single difference between two documents. old is nil for
+// added values and new is nil for removed values.
+type DiffEntry struct {
+	Kind DiffKind
+	path valuePath
+	old  jsonValue
+	new  jsonValue
+}
+
+// Path returns the location of the difference, like ".items[0].name".
+func (e *DiffEntry) Path() string {
+	return e.path.String()
+}
+
+// Pointer returns the location of the difference as a JSON Pointer.
+func (e *DiffEntry) Pointer() string {
+	return e.path.Pointer()
+}
+
+type DiffOptions struct {
+	// IgnoreArrayOrder compares arrays as multisets.
+	IgnoreArrayOrder bool
+	// IgnorePaths lists paths, either JSON Pointers or ".a[0].b", whose
+	// subtrees are not compared. "*" matches any member or element.
+	IgnorePaths []string
+	// Tolerance is the largest difference between two numbers that are
+	// still considered equal.
+	Tolerance float64
+}
+
+type Diff struct {
+	a, b    *decodeResult
+	Entries []*DiffEntry
+}
+
+type differ struct {
+	a, b    *decodeResult
+	opts    DiffOptions
+	ignore  []valuePath
+	entries []*DiffEntry
+	// err is the first error from decoding lazyValues.
+	err error
+}
+
+// values decodes va and vb if they are lazyValues.
+func (d *differ) values(va, vb jsonValue) (jsonValue, jsonValue) {
+	a, err := d.a.value(va)
+	b := vb
+	if err == nil {
+		b, err = d.b.value(vb)
+	}
+	if err != nil {
+		if d.err == nil {
+			d.err = err
+		}
+		return va, vb
+	}
+	return a, b
+}
+
+// CompareDocuments returns the structural differences from a to b.
+func CompareDocuments(a, b *decodeResult, opts *DiffOptions) (*Diff, error) {
+	d := &differ{a: a, b: b}
+	if opts != nil {
+		d.opts = *opts
+	}
+	for _, s := range d.opts.IgnorePaths {
+		pattern, err := parsePathPattern(s)
+		if err != nil {
+			return nil, err
+		}
+		d.ignore = append(d.ignore, pattern)
+	}
+	d.compare(valuePath{}, a.toplevel, b.toplevel)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &Diff{
+		a:       a,
+		b:       b,
+		Entries: d.entries,
+	}, nil
+}
+
+func (d *differ) ignored(path valuePath) bool {
+	for _, pattern := range d.ignore {
+		if path.hasPrefix(pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *differ) add(kind DiffKind, path valuePath, old, new jsonValue) {
+	d.entries = append(d.entries, &DiffEntry{
+		Kind: kind,
+		path: path,
+		old:  old,
+		new:  new,
+	})
+}
+
+// membersByName maps the member names of obj, with their escape sequences
+// resolved, to their values.
+func membersByName(r *decodeResult, obj *objectValue) map[string]jsonValue {
+	members := make(map[string]jsonValue, len(obj.keys))
+	for _, id := range obj.keys {
+		members[r.text(id)] = obj.props[id]
+	}
+	return members
+}
+
+func (d *differ) compare(path valuePath, va, vb jsonValue) {
+	// Edited documents share their unchanged parts with the original.
+	if va == vb || d.ignored(path) {
+		return
+	}
+	va, vb = d.values(va, vb)
+	switch a := va.(type) {
+	case *objectValue:
+		if b, ok := vb.(*objectValue); ok {
+			d.compareObjects(path, a, b)
+			return
+		}
+	case *arrayValue:
+		if b, ok := vb.(*arrayValue); ok {
+			if d.opts.IgnoreArrayOrder {
+				d.compareUnordered(path, a, b)
+			} else {
+				d.compareOrdered(path, a, b)
+			}
+			return
+		}
+	}
+	if !d.equal(path, va, vb) {
+		d.add(DiffChanged, path, va, vb)
+	}
+}
+
+func (d *differ) compareObjects(path valuePath, a, b *objectValue) {
+	membersA := membersByName(d.a, a)
+	membersB := membersByName(d.b, b)
+	for _, id := range a.keys {
+		child := path.child(memberSegment(d.a.str(id)))
+		if vb, ok := membersB[d.a.text(id)]; ok {
+			d.compare(child, a.props[id], vb)
+		} else if !d.ignored(child) {
+			d.add(DiffRemoved, child, a.props[id], nil)
+		}
+	}
+	for _, id := range b.keys {
+		child := path.child(memberSegment(d.b.str(id)))
+		if _, ok := membersA[d.b.text(id)]; !ok && !d.ignored(child) {
+			d.add(DiffAdded, child, nil, b.props[id])
+		}
+	}
+}
+
+func (d *differ) compareOrdered(path valuePath, a, b *arrayValue) {
+	for index := 0; index < len(a.elems) || index < len(b.elems); index++ {
+		child := path.child(indexSegment(index))
+		if index >= len(b.elems) {
+			if !d.ignored(child) {
+				d.add(DiffRemoved, child, a.elems[index], nil)
+			}
+		} else if index >= len(a.elems) {
+			if !d.ignored(child) {
+				d.add(DiffAdded, child, nil, b.elems[index])
+			}
+		} else {
+			d.compare(child, a.elems[index], b.elems[index])
+		}
+	}
+}
+
+func (d *differ) compareUnordered(path valuePath, a, b *arrayValue) {
+	matched := make([]bool, len(b.elems))
+	for i, ea := range a.elems {
+		child := path.child(indexSegment(i))
+		found := false
+		// Try the element at the same position first; it is the most
+		// likely match.
+		if i < len(b.elems) && !matched[i] && d.equal(child, ea, b.elems[i]) {
+			matched[i] = true
+			found = true
+		}
+		for j := 0; !found && j < len(b.elems); j++ {
+			if !matched[j] && d.equal(child, ea, b.elems[j]) {
+				matched[j] = true
+				found = true
+			}
+		}
+		if !found && !d.ignored(child) {
+			d.add(DiffRemoved, child, ea, nil)
+		}
+	}
+	for j, eb := range b.elems {
+		child := path.child(indexSegment(j))
+		if !matched[j] && !d.ignored(child) {
+			d.add(DiffAdded, child, nil, eb)
+		}
+	}
+}
+
+// equal reports whether va and vb have no differences under the options
+// of d.
+func (d *differ) equal(path valuePath, va, vb jsonValue) bool {
+	if va == vb || d.ignored(path) {
+		return true
+	}
+	va, vb = d.values(va, vb)
+	switch a := va.(type) {
+	case *objectValue:
+		b, ok := vb.(*objectValue)
+		if !ok {
+			return false
+		}
+		membersB := membersByName(d.b, b)
+		count := 0
+		for _, id := range a.keys {
+			child := path.child(memberSegment(d.a.str(id)))
+			eb, ok := membersB[d.a.text(id)]
+			if !ok {
+				if !d.ignored(child) {
+					return false
+				}
+				continue
+			}
+			count++
+			if !d.equal(child, a.props[id], eb) {
+				return false
+			}
+		}
+		if count == len(b.keys) {
+			return true
+		}
+		membersA := membersByName(d.a, a)
+		for _, id := range b.keys {
+			if _, ok := membersA[d.b.text(id)]; !ok && !d.ignored(path.child(memberSegment(d.b.str(id)))) {
+				return false
+			}
+		}
+		return true
+	case *arrayValue:
+		b, ok := vb.(*arrayValue)
+		if !ok || len(a.elems) != len(b.elems) {
+			return false
+		}
+		if d.opts.IgnoreArrayOrder {
+			sub := &differ{a: d.a, b: d.b, opts: d.opts, ignore: d.ignore}
+			sub.compareUnordered(path, a, b)
+			return len(sub.entries) == 0
+		}
+		for index := range a.elems {
+			if !d.equal(path.child(indexSegment(index)), a.elems[index], b.elems[index]) {
+				return false
+			}
+		}
+		return true
+	case *stringValue:
+		b, ok := vb.(*stringValue)
+		return ok && d.a.text(a.id) == d.b.text(b.id)
+	case *numberValue:
+		b, ok := vb.(*numberValue)
+		return ok && math.Abs(a.value-b.value) <= d.opts.Tolerance
+	case *literalValue:
+		b, ok := vb.(*literalValue)
+		return ok && a.value == b.value
+	}
+	return false
+}
+
+// DiffFormatter prints a Diff in one of several formats.
+type DiffFormatter struct {
+	d            *Diff
+	w            io.Writer
+	colors       *colorScheme
+	addedColor   *color.Color
+	removedColor *color.Color
+	changedColor *color.Color
+}
+
+func NewDiffFormatter(d *Diff, w io.Writer) *DiffFormatter {
+	color.Output = w
+	color.NoColor = true
+	return &DiffFormatter{
+		d:            d,
+		w:            w,
+		colors:       newColorScheme(),
+		addedColor:   color.New(color.FgGreen),
+		removedColor: color.New(color.FgRed),
+		changedColor: color.New(color.FgYellow),
+	}
+}
+
+func (f *DiffFormatter) EnableColor() {
+	color.NoColor = false
+}
+
+func displayPath(p valuePath) string {
+	if len(p) == 0 {
+		return "."
+	}
+	return p.String()
+}
+
+func (f *DiffFormatter) writeValue(w io.Writer, r *decodeResult, v jsonValue) {
+	vw := &valueWriter{w: w, symtab: r.symtab, colors: *f.colors, resolve: r.resolveLazy}
+	vw.write(v, "")
+}
+
+// WriteText prints one line per difference, like
+// "~ .a.b: 1 -> 2".
+func (f *DiffFormatter) WriteText() error {
+	var buf bytes.Buffer
+	for _, e := range f.d.Entries {
+		path := displayPath(e.path)
+		switch e.Kind {
+		case DiffAdded:
+			f.addedColor.Fprintf(&buf, "+ %s: ", path)
+			f.writeValue(&buf, f.d.b, e.new)
+		case DiffRemoved:
+			f.removedColor.Fprintf(&buf, "- %s: ", path)
+			f.writeValue(&buf, f.d.a, e.old)
+		case DiffChanged:
+			f.changedColor.Fprintf(&buf, "~ %s: ", path)
+			f.writeValue(&buf, f.d.a, e.old)
+			buf.WriteString(" -> ")
+			f.writeValue(&buf, f.d.b, e.new)
+		}
+		buf.WriteString("\n")
+	}
+	_, err := buf.WriteTo(f.w)
+	return err
+}
+
+func (f *DiffFormatter) writeLines(buf *bytes.Buffer, c *color.Color, prefix string, r *decodeResult, v jsonValue) {
+	var text bytes.Buffer
+	vw := &valueWriter{w: &text, symtab: r.symtab, indentUnit: "  "}
+	vw.write(v, "")
+	for _, line := range strings.Split(text.String(), "\n") {
+		c.Fprintf(buf, "%s%s\n", prefix, line)
+	}
+}
+
+// WriteUnified prints the differences in the style of a unified diff,
+// with one hunk per difference headed by its path.
+func (f *DiffFormatter) WriteUnified(nameA, nameB string) error {
+	var buf bytes.Buffer
+	if len(f.d.Entries) > 0 {
+		f.removedColor.Fprintf(&buf, "--- %s\n", nameA)
+		f.addedColor.Fprintf(&buf, "+++ %s\n", nameB)
+	}
+	for _, e := range f.d.Entries {
+		f.changedColor.Fprintf(&buf, "@@ %s @@\n", displayPath(e.path))
+		if e.old != nil {
+			f.writeLines(&buf, f.removedColor, "-", f.d.a, e.old)
+		}
+		if e.new != nil {
+			f.writeLines(&buf, f.addedColor, "+", f.d.b, e.new)
+		}
+	}
+	_, err := buf.WriteTo(f.w)
+	return err
+}
+
+// WriteJSON prints the differences as a JSON array of objects with "op",
+// "path" (a JSON Pointer), "old" and "new" members.
+func (f *DiffFormatter) WriteJSON() error {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for n, e := range f.d.Entries {
+		if n > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `{"op":"%s","path":%s`, e.Kind, quoteString(e.path.Pointer()))
+		if e.old != nil {
+			buf.WriteString(`,"old":`)
+			buf.WriteString(f.d.a.encodeValue(e.old))
+		}
+		if e.new != nil {
+			buf.WriteString(`,"new":`)
+			buf.WriteString(f.d.b.encodeValue(e.new))
+		}
+		buf.WriteString("}")
+	}
+	buf.WriteString("]")
+	if len(f.d.Entries) == 0 {
+		_, err := fmt.Fprintln(f.w, "[]")
+		return err
+	}
+	formatter := NewFormatter(&buf, f.w)
+	return formatter.Dump()
+}
@@ -0,0 +1,139 @@
+package jsontools
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustDecode(t *testing.T, s string) *decodeResult {
+	r, err := Decode(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func diffPaths(t *testing.T, a, b string, opts *DiffOptions) []string {
+	d, err := CompareDocuments(mustDecode(t, a), mustDecode(t, b), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, e := range d.Entries {
+		paths = append(paths, e.Kind.String()+" "+e.Path())
+	}
+	return paths
+}
+
+func TestDiff(t *testing.T) {
+	assert.Equal(t, []string(nil), diffPaths(t,
+		`{"a": 1, "b": [1, 2]}`, `{"b": [1, 2], "a": 1}`, nil))
+	assert.Equal(t, []string{
+		"changed .a",
+		"removed .b[1]",
+		"removed .c",
+		"added .d",
+	}, diffPaths(t,
+		`{"a": 1, "b": [1, 2], "c": "x"}`,
+		`{"a": "1", "b": [1], "d": null}`, nil))
+	assert.Equal(t, []string{"changed .x[0].y"}, diffPaths(t,
+		`{"x": [{"y": true}]}`, `{"x": [{"y": false}]}`, nil))
+	assert.Equal(t, []string(nil), diffPaths(t,
+		`{"\u0041": "\u00e9\n"}`, `{"A": "é\u000a"}`, nil))
+}
+
+func TestDiffOptions(t *testing.T) {
+	assert.Equal(t, []string{"changed [0]", "changed [1]"}, diffPaths(t,
+		`[1, 2]`, `[2, 1]`, nil))
+	assert.Equal(t, []string(nil), diffPaths(t,
+		`[1, 2, {"a": [3, 4]}]`, `[{"a": [4, 3]}, 2, 1]`,
+		&DiffOptions{IgnoreArrayOrder: true}))
+	assert.Equal(t, []string{"removed [1]", "added [2]"}, diffPaths(t,
+		`[1, 2, 3]`, `[3, 1, 4]`,
+		&DiffOptions{IgnoreArrayOrder: true}))
+	assert.Equal(t, []string{"changed .b"}, diffPaths(t,
+		`{"a": {"t": 1}, "b": 2, "c": [{"id": 1}]}`,
+		`{"a": {"t": 2}, "b": 3, "c": [{"id": 2}]}`,
+		&DiffOptions{IgnorePaths: []string{"/a/t", ".c[*].id"}}))
+	assert.Equal(t, []string{"changed [1]"}, diffPaths(t,
+		`[1.0, 2.0]`, `[1.05, 2.5]`,
+		&DiffOptions{Tolerance: 0.1}))
+}
+
+func TestDiffFormatter(t *testing.T) {
+	d, err := CompareDocuments(
+		mustDecode(t, `{"a": 1, "b": {"c": [true]}}`),
+		mustDecode(t, `{"a": 2, "d": "x"}`), nil)
+	assert.Nil(t, err)
+
+	w := new(bytes.Buffer)
+	assert.Nil(t, NewDiffFormatter(d, w).WriteText())
+	assert.Equal(t, `~ .a: 1 -> 2
+- .b: {"c":[true]}
++ .d: "x"
+`, w.String())
+
+	w.Reset()
+	assert.Nil(t, NewDiffFormatter(d, w).WriteUnified("a.json", "b.json"))
+	assert.Equal(t, `--- a.json
++++ b.json
+@@ .a @@
+-1
++2
+@@ .b @@
+-{
+-  "c": [
+-    true
+-  ]
+-}
+@@ .d @@
++"x"
+`, w.String())
+
+	w.Reset()
+	assert.Nil(t, NewDiffFormatter(d, w).WriteJSON())
+	assert.Equal(t, `[
+  {
+    "op": "changed",
+    "path": "/a",
+    "old": 1,
+    "new": 2
+  },
+  {
+    "op": "removed",
+    "path": "/b",
+    "old": {
+      "c": [
+        true
+      ]
+    }
+  },
+  {
+    "op": "added",
+    "path": "/d",
+    "new": "x"
+  }
+]
+`, w.String())
+
+	d, err = CompareDocuments(mustDecode(t, `{"a\"b": 1, "c\\/d": 2}`), mustDecode(t, `{}`), nil)
+	assert.Nil(t, err)
+	w.Reset()
+	assert.Nil(t, NewDiffFormatter(d, w).WriteJSON())
+	assert.Equal(t, `[
+  {
+    "op": "removed",
+    "path": "/a\"b",
+    "old": 1
+  },
+  {
+    "op": "removed",
+    "path": "/c\\~1d",
+    "old": 2
+  }
+]
+`, w.String())
+}
@@ -0,0 +1,244 @@
+package jsontools
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+)
+
+var differColor = color.New(color.FgYellow)
+
+// missingCell stands for a value which a document lacks in compare.
+const missingCell = "-"
+
+// displayName returns the name the doc command shows for the nth
+// document.
+func (d *document) displayName(n int) string {
+	switch {
+	case d.name != "":
+		return d.name
+	case d.File != "":
+		return d.File
+	}
+	return fmt.Sprintf("document %d", n+1)
+}
+
+func (i *Inspector) docIndex() int {
+	for n, d := range i.docs {
+		if d == i.document {
+			return n
+		}
+	}
+	return -1
+}
+
+// docCommand lists the documents, or switches to the one given by its
+// number or name.
+func (i *Inspector) docCommand(args string) error {
+	if args == "" {
+		for n, d := range i.docs {
+			marker := " "
+			if d == i.document {
+				marker = "*"
+			}
+			fmt.Fprintf(i.out, "%s %d: %s", marker, n+1, d.displayName(n))
+			if d.modified() {
+				metaColor.Fprintf(i.out, " (modified)")
+			}
+			fmt.Fprintln(i.out)
+		}
+		return nil
+	}
+	if n, err := strconv.Atoi(args); err == nil {
+		if n < 1 || n > len(i.docs) {
+			return fmt.Errorf("no document %d", n)
+		}
+		i.document = i.docs[n-1]
+		return nil
+	}
+	for n, d := range i.docs {
+		if d.displayName(n) == args {
+			i.document = d
+			return nil
+		}
+	}
+	return fmt.Errorf("no document %q", args)
+}
+
+// compareRow is a row of the compare table: the value of a member or
+// element in each document, or nil where a document lacks it.
+type compareRow struct {
+	name   string
+	values []jsonValue
+}
+
+// differs reports whether the documents disagree about the row.
+func (i *Inspector) differs(row *compareRow) bool {
+	first := -1
+	for n, v := range row.values {
+		if v == nil {
+			if first >= 0 {
+				return true
+			}
+			continue
+		}
+		if first < 0 {
+			if n > 0 {
+				return true
+			}
+			first = n
+			continue
+		}
+		d := &differ{a: i.docs[first].json, b: i.docs[n].json}
+		if !d.equal(valuePath{}, row.values[first], v) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareRows returns the rows comparing values, which belong to the
+// documents of i in turn. If they are all containers, there is a row for
+// each of their members or elements, and otherwise a single row named
+// name.
+func (i *Inspector) compareRows(name string, values []jsonValue) ([]*compareRow, error) {
+	containers := true
+	for _, v := range values {
+		switch v.(type) {
+		case *objectValue, *arrayValue, nil:
+		default:
+			containers = false
+		}
+	}
+	if !containers {
+		return []*compareRow{{name: name, values: values}}, nil
+	}
+
+	var rows []*compareRow
+	byName := make(map[string]*compareRow)
+	row := func(name string) *compareRow {
+		r := byName[name]
+		if r == nil {
+			r = &compareRow{name: name, values: make([]jsonValue, len(values))}
+			byName[name] = r
+			rows = append(rows, r)
+		}
+		return r
+	}
+	current := i.document
+	defer func() { i.document = current }()
+	for n, v := range values {
+		i.document = i.docs[n]
+		var names []string
+		var children []jsonValue
+		switch value := v.(type) {
+		case *objectValue:
+			for _, id := range value.keys {
+				names = append(names, i.idToStr(id))
+				children = append(children, value.props[id])
+			}
+		case *arrayValue:
+			for index, e := range value.elems {
+				names = append(names, strconv.Itoa(index))
+				children = append(children, e)
+			}
+		}
+		for c, child := range children {
+			resolved, err := i.resolve(child)
+			if err != nil {
+				return nil, err
+			}
+			row(names[c]).values[n] = resolved
+		}
+	}
+	return rows, nil
+}
+
+// compareCommand shows the value at a path in each document side by side,
+// highlighting the members or elements in which they differ.
+func (i *Inspector) compareCommand(args string) error {
+	fs := newFlagSet("compare")
+	width := fs.Int("w", 24, "maximum width of a cell")
+	onlyDiffs := fs.Bool("d", false, "only show the rows which differ")
+	if err := fs.Parse(splitFields(args)); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(1))
+	}
+	path, err := i.absolutePath(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	current := i.document
+	defer func() { i.document = current }()
+	values := make([]jsonValue, len(i.docs))
+	for n, d := range i.docs {
+		i.document = d
+		v, err := i.get(path)
+		if err != nil {
+			// The document lacks the path.
+			continue
+		}
+		if values[n], err = i.resolve(v); err != nil {
+			return err
+		}
+	}
+	i.document = current
+	name := "/" + strings.Join(path, "/")
+	rows, err := i.compareRows(name, values)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	for n, d := range i.docs {
+		fmt.Fprintf(w, "\t%s", truncateText(d.displayName(n), *width))
+	}
+	fmt.Fprintln(w)
+	var differing []bool
+	for _, row := range rows {
+		differs := i.differs(row)
+		if *onlyDiffs && !differs {
+			continue
+		}
+		differing = append(differing, differs)
+		fmt.Fprint(w, truncateText(row.name, *width))
+		for n, v := range row.values {
+			text := missingCell
+			if v != nil {
+				i.document = i.docs[n]
+				text = truncateText(i.cellText(v), *width)
+			}
+			fmt.Fprintf(w, "\t%s", text)
+		}
+		fmt.Fprintln(w)
+	}
+	i.document = current
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	// Color whole lines so that the escape sequences don't upset the
+	// alignment.
+	lines := strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	metaColor.Fprint(i.out, lines[0])
+	for n, line := range lines[1:] {
+		if differing[n] {
+			differColor.Fprint(i.out, line)
+		} else {
+			fmt.Fprint(i.out, line)
+		}
+	}
+	fmt.Fprintln(i.out)
+	if len(differing) == 0 {
+		metaColor.Fprintf(i.out, "No differences\n")
+	}
+	return nil
+}
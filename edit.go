@@ -0,0 +1,636 @@
+package jsontools
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Edits never modify values in place. Each one builds a new root which
+// shares everything but the containers along the edited path with the
+// old one, so undo only has to keep the old roots around.
+
+// editCommands are the inspector commands which change the document or
+// deal with the changes.
+var editCommands = map[string]func(*Inspector, string) error{
+	"set":    (*Inspector).setCommand,
+	"rm":     (*Inspector).rmCommand,
+	"mv":     (*Inspector).mvCommand,
+	"cp":     (*Inspector).cpCommand,
+	"append": (*Inspector).appendCommand,
+	"rename": (*Inspector).renameCommand,
+	"undo":   (*Inspector).undoCommand,
+	"redo":   (*Inspector).redoCommand,
+	"diff":   (*Inspector).diffCommand,
+	"save":   (*Inspector).saveCommand,
+}
+
+// The indentation of the source file is guessed from this many bytes.
+const indentSampleSize = 4096
+
+func (i *Inspector) root() jsonValue {
+	return i.stack[0].value
+}
+
+// setRoot replaces the document, staying at the current path as far as it
+// still exists.
+func (i *Inspector) setRoot(root jsonValue) {
+	segments := i.segments()
+	i.json.toplevel = root
+	i.stack, _ = i.descend([]*stackItem{{value: root}}, segments)
+}
+
+// commit makes root the document, remembering the old one for undo.
+func (i *Inspector) commit(root jsonValue) {
+	i.undoRoots = append(i.undoRoots, i.root())
+	i.redoRoots = nil
+	i.setRoot(root)
+}
+
+// absolutePath returns the segments leading from the root to the value
+// named by the path arg, which is relative unless it starts with '/'.
+func (i *Inspector) absolutePath(arg string) ([]string, error) {
+	segments, absolute, err := parsePath(arg)
+	if err != nil {
+		return nil, err
+	}
+	var path []string
+	if !absolute {
+		path = i.segments()
+	}
+	for _, name := range segments {
+		switch name {
+		case ".":
+		case "..":
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		default:
+			path = append(path, name)
+		}
+	}
+	return path, nil
+}
+
+// parseValue decodes text into a value of the document. It is wrapped in
+// an array since the decoder only accepts containers at the top level.
+func (i *Inspector) parseValue(text string) (jsonValue, error) {
+	doc, err := Decode(strings.NewReader("[" + text + "]"))
+	if err != nil {
+		return nil, err
+	}
+	arr := doc.toplevel.(*arrayValue)
+	if len(arr.elems) != 1 {
+		return nil, fmt.Errorf("expected a single JSON value: %s", text)
+	}
+	return i.json.importValue(doc, arr.elems[0]), nil
+}
+
+func (i *Inspector) memberId(obj *objectValue, name string) (uint, bool) {
+	for _, id := range obj.keys {
+		if i.idToStr(id) == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// elemIndex parses name as an index into arr, counting from the end if it
+// is negative. If end is set, the index just past the last element is
+// allowed too.
+func elemIndex(arr *arrayValue, name string, end bool) (int, error) {
+	index, err := strconv.Atoi(name)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an array index", name)
+	}
+	if index < 0 {
+		index += len(arr.elems)
+	}
+	limit := len(arr.elems)
+	if end {
+		limit++
+	}
+	if index < 0 || index >= limit {
+		return 0, fmt.Errorf("no element %s", name)
+	}
+	return index, nil
+}
+
+func copyObject(obj *objectValue) *objectValue {
+	c := &objectValue{
+		props: make(map[uint]jsonValue, len(obj.props)),
+		keys:  append([]uint(nil), obj.keys...),
+	}
+	for id, v := range obj.props {
+		c.props[id] = v
+	}
+	return c
+}
+
+func copyArray(arr *arrayValue) *arrayValue {
+	return &arrayValue{elems: append([]jsonValue(nil), arr.elems...)}
+}
+
+// get returns the value at path.
+func (i *Inspector) get(path []string) (jsonValue, error) {
+	v := i.root()
+	for n, name := range path {
+		resolved, err := i.json.value(v)
+		if err != nil {
+			return nil, err
+		}
+		switch cur := resolved.(type) {
+		case *objectValue:
+			id, ok := i.memberId(cur, name)
+			if !ok {
+				return nil, fmt.Errorf("%s: no such member", strings.Join(path[:n+1], "/"))
+			}
+			v = cur.props[id]
+		case *arrayValue:
+			index, err := elemIndex(cur, name, false)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", strings.Join(path[:n], "/"), err)
+			}
+			v = cur.elems[index]
+		default:
+			return nil, fmt.Errorf("%s: not an object or array", strings.Join(path[:n], "/"))
+		}
+	}
+	return v, nil
+}
+
+// update returns a copy of v in which the value at path is replaced by
+// the result of change. Only the containers along path are copied.
+func (i *Inspector) update(v jsonValue, path []string, change func(jsonValue) (jsonValue, error)) (jsonValue, error) {
+	v, err := i.json.value(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return change(v)
+	}
+	switch cur := v.(type) {
+	case *objectValue:
+		id, ok := i.memberId(cur, path[0])
+		if !ok {
+			return nil, fmt.Errorf("no member %q", path[0])
+		}
+		child, err := i.update(cur.props[id], path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		obj := copyObject(cur)
+		obj.props[id] = child
+		return obj, nil
+	case *arrayValue:
+		index, err := elemIndex(cur, path[0], false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := i.update(cur.elems[index], path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		arr := copyArray(cur)
+		arr.elems[index] = child
+		return arr, nil
+	}
+	return nil, fmt.Errorf("cannot look up %q in a primitive value", path[0])
+}
+
+// updateParent is like update, but calls change with the container of the
+// value at path and the last segment of path.
+func (i *Inspector) updateParent(root jsonValue, path []string, change func(jsonValue, string) (jsonValue, error)) (jsonValue, error) {
+	parent, name := path[:len(path)-1], path[len(path)-1]
+	return i.update(root, parent, func(v jsonValue) (jsonValue, error) {
+		return change(v, name)
+	})
+}
+
+// put returns a copy of container with its member or element name set to
+// value. Existing members keep their place and new ones are added at the
+// end; the index just past the end of an array appends.
+func (i *Inspector) put(container jsonValue, name string, value jsonValue) (jsonValue, error) {
+	switch cur := container.(type) {
+	case *objectValue:
+		id, ok := i.memberId(cur, name)
+		if !ok {
+			id = i.json.intern(name)
+		}
+		obj := copyObject(cur)
+		obj.set(id, value)
+		return obj, nil
+	case *arrayValue:
+		index, err := elemIndex(cur, name, true)
+		if err != nil {
+			return nil, err
+		}
+		arr := copyArray(cur)
+		if index == len(arr.elems) {
+			arr.elems = append(arr.elems, value)
+		} else {
+			arr.elems[index] = value
+		}
+		return arr, nil
+	}
+	return nil, fmt.Errorf("cannot set %q in a primitive value", name)
+}
+
+// without returns a copy of container without its member or element name.
+func (i *Inspector) without(container jsonValue, name string) (jsonValue, error) {
+	switch cur := container.(type) {
+	case *objectValue:
+		id, ok := i.memberId(cur, name)
+		if !ok {
+			return nil, fmt.Errorf("no member %q", name)
+		}
+		obj := copyObject(cur)
+		obj.remove(id)
+		return obj, nil
+	case *arrayValue:
+		index, err := elemIndex(cur, name, false)
+		if err != nil {
+			return nil, err
+		}
+		arr := &arrayValue{elems: make([]jsonValue, 0, len(cur.elems)-1)}
+		arr.elems = append(arr.elems, cur.elems[:index]...)
+		arr.elems = append(arr.elems, cur.elems[index+1:]...)
+		return arr, nil
+	}
+	return nil, fmt.Errorf("cannot remove %q from a primitive value", name)
+}
+
+// set returns a copy of root with the value at path set to value.
+func (i *Inspector) set(root jsonValue, path []string, value jsonValue) (jsonValue, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return i.updateParent(root, path, func(parent jsonValue, name string) (jsonValue, error) {
+		return i.put(parent, name, value)
+	})
+}
+
+// remove returns a copy of root without the value at path.
+func (i *Inspector) remove(root jsonValue, path []string) (jsonValue, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot remove the root")
+	}
+	return i.updateParent(root, path, i.without)
+}
+
+// splitArgs splits args into its first word and the rest, and fails
+// unless both are present. Spaces within double quotes don't end the
+// first word.
+func splitArgs(args, usage string) (string, string, error) {
+	quoted := false
+	end := len(args)
+	for n := 0; n < len(args) && end == len(args); n++ {
+		switch args[n] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if quoted {
+				n++
+			}
+		case ' ':
+			if !quoted {
+				end = n
+			}
+		}
+	}
+	first, rest := args[:end], strings.TrimSpace(args[end:])
+	if first == "" || rest == "" {
+		return "", "", fmt.Errorf("usage: %s", usage)
+	}
+	return first, rest, nil
+}
+
+func (i *Inspector) setCommand(args string) error {
+	path, text, err := splitArgs(args, "set <path> <json>")
+	if err != nil {
+		return err
+	}
+	absolute, err := i.absolutePath(path)
+	if err != nil {
+		return err
+	}
+	value, err := i.parseValue(text)
+	if err != nil {
+		return err
+	}
+	root, err := i.set(i.root(), absolute, value)
+	if err != nil {
+		return err
+	}
+	i.commit(root)
+	return nil
+}
+
+func (i *Inspector) rmCommand(args string) error {
+	if args == "" {
+		return errors.New("usage: rm <path>")
+	}
+	path, err := i.absolutePath(args)
+	if err != nil {
+		return err
+	}
+	root, err := i.remove(i.root(), path)
+	if err != nil {
+		return err
+	}
+	i.commit(root)
+	return nil
+}
+
+// hasPathPrefix reports whether prefix is path or one of its ancestors.
+func hasPathPrefix(path, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for n, name := range prefix {
+		if path[n] != name {
+			return false
+		}
+	}
+	return true
+}
+
+// mvCommand removes the value and then adds it at the destination, so
+// like a JSON Patch move, indices in the destination refer to the array
+// after the removal.
+func (i *Inspector) mvCommand(args string) error {
+	from, to, err := splitArgs(args, "mv <from> <to>")
+	if err != nil {
+		return err
+	}
+	fromPath, err := i.absolutePath(from)
+	if err != nil {
+		return err
+	}
+	toPath, err := i.absolutePath(to)
+	if err != nil {
+		return err
+	}
+	if hasPathPrefix(toPath, fromPath) {
+		return fmt.Errorf("cannot move %s into itself", from)
+	}
+	value, err := i.get(fromPath)
+	if err != nil {
+		return err
+	}
+	root, err := i.remove(i.root(), fromPath)
+	if err != nil {
+		return err
+	}
+	if root, err = i.set(root, toPath, value); err != nil {
+		return err
+	}
+	i.commit(root)
+	return nil
+}
+
+func (i *Inspector) cpCommand(args string) error {
+	from, to, err := splitArgs(args, "cp <from> <to>")
+	if err != nil {
+		return err
+	}
+	fromPath, err := i.absolutePath(from)
+	if err != nil {
+		return err
+	}
+	toPath, err := i.absolutePath(to)
+	if err != nil {
+		return err
+	}
+	value, err := i.get(fromPath)
+	if err != nil {
+		return err
+	}
+	root, err := i.set(i.root(), toPath, value)
+	if err != nil {
+		return err
+	}
+	i.commit(root)
+	return nil
+}
+
+func (i *Inspector) appendCommand(args string) error {
+	path, text, err := splitArgs(args, "append <path> <json>")
+	if err != nil {
+		return err
+	}
+	absolute, err := i.absolutePath(path)
+	if err != nil {
+		return err
+	}
+	value, err := i.parseValue(text)
+	if err != nil {
+		return err
+	}
+	root, err := i.update(i.root(), absolute, func(v jsonValue) (jsonValue, error) {
+		arr, ok := v.(*arrayValue)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an array", path)
+		}
+		arr = copyArray(arr)
+		arr.elems = append(arr.elems, value)
+		return arr, nil
+	})
+	if err != nil {
+		return err
+	}
+	i.commit(root)
+	return nil
+}
+
+func (i *Inspector) renameCommand(args string) error {
+	path, name, err := splitArgs(args, "rename <path> <name>")
+	if err != nil {
+		return err
+	}
+	absolute, err := i.absolutePath(path)
+	if err != nil {
+		return err
+	}
+	// The new name may be quoted like a path segment.
+	segments, _, err := parsePath(name)
+	if err != nil {
+		return err
+	}
+	if len(segments) != 1 {
+		return fmt.Errorf("invalid member name %s", name)
+	}
+	newName := segments[0]
+	if len(absolute) == 0 {
+		return errors.New("cannot rename the root")
+	}
+	root, err := i.updateParent(i.root(), absolute, func(parent jsonValue, name string) (jsonValue, error) {
+		obj, ok := parent.(*objectValue)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an object member", path)
+		}
+		id, ok := i.memberId(obj, name)
+		if !ok {
+			return nil, fmt.Errorf("no member %q", name)
+		}
+		if _, ok := i.memberId(obj, newName); ok {
+			return nil, fmt.Errorf("member %q already exists", newName)
+		}
+		newId := i.json.intern(newName)
+		obj = copyObject(obj)
+		for n, k := range obj.keys {
+			if k == id {
+				obj.keys[n] = newId
+			}
+		}
+		obj.props[newId] = obj.props[id]
+		delete(obj.props, id)
+		return obj, nil
+	})
+	if err != nil {
+		return err
+	}
+	i.commit(root)
+	return nil
+}
+
+func (i *Inspector) undoCommand(args string) error {
+	if len(i.undoRoots) == 0 {
+		return errors.New("nothing to undo")
+	}
+	last := len(i.undoRoots) - 1
+	i.redoRoots = append(i.redoRoots, i.root())
+	root := i.undoRoots[last]
+	i.undoRoots = i.undoRoots[:last]
+	i.setRoot(root)
+	return nil
+}
+
+func (i *Inspector) redoCommand(args string) error {
+	if len(i.redoRoots) == 0 {
+		return errors.New("nothing to redo")
+	}
+	last := len(i.redoRoots) - 1
+	i.undoRoots = append(i.undoRoots, i.root())
+	root := i.redoRoots[last]
+	i.redoRoots = i.redoRoots[:last]
+	i.setRoot(root)
+	return nil
+}
+
+// changes returns the differences between the document as last loaded or
+// saved and the current one.
+func (i *Inspector) changes() (*Diff, error) {
+	saved := &decodeResult{
+		toplevel:    i.saved,
+		symtab:      i.json.symtab,
+		resolveLazy: i.json.resolveLazy,
+	}
+	return CompareDocuments(saved, i.json, nil)
+}
+
+func (i *Inspector) diffCommand(args string) error {
+	d, err := i.changes()
+	if err != nil {
+		return err
+	}
+	if len(d.Entries) == 0 {
+		metaColor.Fprintf(i.out, "No changes\n")
+		return nil
+	}
+	// NewDiffFormatter turns colors off for everyone.
+	noColor := color.NoColor
+	f := NewDiffFormatter(d, i.out)
+	if !noColor {
+		f.EnableColor()
+	}
+	return f.WriteText()
+}
+
+// detectIndent guesses the indentation unit of the JSON text starting
+// with sample: empty if it is all on one line, and two spaces if no line
+// is indented.
+func detectIndent(sample []byte) string {
+	lines := bytes.Split(bytes.TrimRight(sample, " \t\r\n"), []byte("\n"))
+	if len(lines) == 1 {
+		return ""
+	}
+	for _, line := range lines[1:] {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) > 0 && len(trimmed) < len(line) {
+			return string(line[:len(line)-len(trimmed)])
+		}
+	}
+	return "  "
+}
+
+// save writes the document to name, indented like the file it was loaded
+// from, and makes name the document's file.
+func (i *Inspector) save(name string) error {
+	indent := "  "
+	if f, err := os.Open(i.File); err == nil {
+		sample := make([]byte, indentSampleSize)
+		n, _ := io.ReadFull(f, sample)
+		f.Close()
+		indent = detectIndent(sample[:n])
+	}
+
+	// Write to a temporary file and rename it, which also leaves a memory
+	// mapping of the original file intact.
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(name); err == nil {
+		f.Chmod(info.Mode().Perm())
+	}
+	w := bufio.NewWriter(f)
+	vw := &valueWriter{
+		w:          w,
+		symtab:     i.json.symtab,
+		indentUnit: indent,
+		resolve:    i.json.resolveLazy,
+	}
+	vw.write(i.root(), "")
+	w.WriteString("\n")
+	err = vw.err
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return err
+	}
+	i.File = name
+	i.saved = i.root()
+	return nil
+}
+
+func (i *Inspector) saveCommand(args string) error {
+	name := args
+	if name == "" {
+		name = i.File
+	}
+	if name == "" {
+		return errors.New("usage: save <file>")
+	}
+	if err := i.save(name); err != nil {
+		return err
+	}
+	metaColor.Fprintf(i.out, "Saved %s\n", name)
+	return nil
+}
@@ -0,0 +1,242 @@
+package jsontools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// valueWriter serializes decoded values back to JSON text.
+type valueWriter struct {
+	w      io.Writer
+	symtab symbolTable
+	// indentUnit is empty for compact output.
+	indentUnit string
+	// colors is the zero colorScheme for plain output.
+	colors colorScheme
+	// resolve decodes lazyValues; err holds the first error it returned.
+	resolve func(jsonValue) (jsonValue, error)
+	err     error
+}
+
+func (vw *valueWriter) token(c *color.Color, s string) {
+	if c == nil {
+		io.WriteString(vw.w, s)
+		return
+	}
+	c.Fprintf(vw.w, "%s", s)
+}
+
+func (vw *valueWriter) newline(indent string) {
+	if vw.indentUnit != "" {
+		io.WriteString(vw.w, "\n"+indent)
+	}
+}
+
+func (vw *valueWriter) write(v jsonValue, indent string) {
+	if vw.resolve != nil {
+		resolved, err := vw.resolve(v)
+		if err != nil {
+			if vw.err == nil {
+				vw.err = err
+			}
+			return
+		}
+		v = resolved
+	}
+	switch value := v.(type) {
+	case *objectValue:
+		if len(value.keys) == 0 {
+			io.WriteString(vw.w, "{}")
+			return
+		}
+		io.WriteString(vw.w, "{")
+		inner := indent + vw.indentUnit
+		for n, id := range value.keys {
+			if n > 0 {
+				io.WriteString(vw.w, ",")
+			}
+			vw.newline(inner)
+			vw.token(vw.colors.memberColor, `"`+vw.symtab[id]+`"`)
+			if vw.indentUnit != "" {
+				io.WriteString(vw.w, ": ")
+			} else {
+				io.WriteString(vw.w, ":")
+			}
+			vw.write(value.props[id], inner)
+		}
+		vw.newline(indent)
+		io.WriteString(vw.w, "}")
+	case *arrayValue:
+		if len(value.elems) == 0 {
+			io.WriteString(vw.w, "[]")
+			return
+		}
+		io.WriteString(vw.w, "[")
+		inner := indent + vw.indentUnit
+		for n, e := range value.elems {
+			if n > 0 {
+				io.WriteString(vw.w, ",")
+			}
+			vw.newline(inner)
+			vw.write(e, inner)
+		}
+		vw.newline(indent)
+		io.WriteString(vw.w, "]")
+	case *stringValue:
+		vw.token(vw.colors.stringColor, `"`+vw.symtab[value.id]+`"`)
+	case *numberValue:
+		vw.token(vw.colors.numberColor, value.jsonText())
+	case *literalValue:
+		vw.token(vw.colors.literalColor, value.value.String())
+	}
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+// encodeValue returns the compact JSON text of v.
+func (r *decodeResult) encodeValue(v jsonValue) string {
+	var buf bytes.Buffer
+	vw := &valueWriter{w: &buf, symtab: r.symtab, resolve: r.resolveLazy}
+	vw.write(v, "")
+	return buf.String()
+}
+
+// Encode writes the document as JSON text, indented by indentWidth
+// spaces, or compact if indentWidth is zero.
+func (r *decodeResult) Encode(w io.Writer, indentWidth int) error {
+	return r.encode(w, indentWidth, colorScheme{})
+}
+
+// EncodeColor is like Encode but highlights the tokens in the colors
+// Formatter uses.
+func (r *decodeResult) EncodeColor(w io.Writer, indentWidth int) error {
+	colors := newColorScheme()
+	for _, c := range []*color.Color{colors.memberColor, colors.stringColor, colors.numberColor, colors.literalColor} {
+		c.EnableColor()
+	}
+	return r.encode(w, indentWidth, *colors)
+}
+
+func (r *decodeResult) encode(w io.Writer, indentWidth int, colors colorScheme) error {
+	var buf bytes.Buffer
+	vw := &valueWriter{
+		w:          &buf,
+		symtab:     r.symtab,
+		indentUnit: string(bytes.Repeat([]byte(" "), indentWidth)),
+		colors:     colors,
+		resolve:    r.resolveLazy,
+	}
+	vw.write(r.toplevel, "")
+	if vw.err != nil {
+		return vw.err
+	}
+	buf.WriteString("\n")
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// quoteString returns s as a JSON string literal.
+func quoteString(s string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for _, c := range s {
+		switch c {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				fmt.Fprintf(&buf, `\u%04x`, c)
+			} else {
+				buf.WriteRune(c)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// escapeString returns s as it appears between the quotes of a JSON
+// string.
+func escapeString(s string) string {
+	q := quoteString(s)
+	return q[1 : len(q)-1]
+}
+
+// unquoteRaw resolves the escape sequences of a string as it appears
+// between the quotes in the source. Invalid escapes are kept as is.
+func unquoteRaw(raw string) string {
+	if strings.IndexByte(raw, '\\') < 0 {
+		return raw
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c != '\\' || i+1 >= len(raw) {
+			buf.WriteByte(c)
+			continue
+		}
+		i++
+		switch raw[i] {
+		case 'b':
+			buf.WriteByte('\b')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case 'u':
+			r, n := unquoteUnicode(raw[i+1:])
+			if n == 0 {
+				buf.WriteString(`\u`)
+				continue
+			}
+			buf.WriteRune(r)
+			i += n
+		default:
+			buf.WriteByte(raw[i])
+		}
+	}
+	return buf.String()
+}
+
+// unquoteUnicode decodes the hex digits following "\u", including a
+// trailing low surrogate, and returns the rune and the number of bytes
+// consumed.
+func unquoteUnicode(s string) (rune, int) {
+	if len(s) < 4 {
+		return 0, 0
+	}
+	n, err := strconv.ParseUint(s[:4], 16, 32)
+	if err != nil {
+		return 0, 0
+	}
+	r := rune(n)
+	if utf16.IsSurrogate(r) && len(s) >= 10 && s[4:6] == `\u` {
+		if low, err := strconv.ParseUint(s[6:10], 16, 32); err == nil {
+			if d := utf16.DecodeRune(r, rune(low)); d != utf8.RuneError {
+				return d, 10
+			}
+		}
+	}
+	return r, 4
+}
@@ -12,16 +12,29 @@ const (
 	defaultIndentSize = 2
 )
 
+// colorScheme holds the colors used to highlight each kind of token.
+type colorScheme struct {
+	memberColor  *color.Color
+	stringColor  *color.Color
+	numberColor  *color.Color
+	literalColor *color.Color
+}
+
+func newColorScheme() *colorScheme {
+	return &colorScheme{
+		memberColor:  color.New(color.FgMagenta),
+		stringColor:  color.New(color.FgRed),
+		numberColor:  color.New(color.FgBlue),
+		literalColor: color.New(color.FgCyan),
+	}
+}
+
 // formatClient is a ParserClient for Printer
 type formatClient struct {
 	w          io.Writer
 	indent     string
 	indentUnit string
-	// colorize funcs
-	memberColor  *color.Color
-	stringColor  *color.Color
-	numberColor  *color.Color
-	literalColor *color.Color
+	*colorScheme
 }
 
 func (c *formatClient) enterBlock() {
@@ -108,12 +121,9 @@ func NewFormatter(r io.Reader, w io.Writer) *Formatter {
 	color.Output = w
 	color.NoColor = true
 	client := &formatClient{
-		w:            w,
-		indent:       "",
-		memberColor:  color.New(color.FgMagenta),
-		stringColor:  color.New(color.FgRed),
-		numberColor:  color.New(color.FgBlue),
-		literalColor: color.New(color.FgCyan),
+		w:           w,
+		indent:      "",
+		colorScheme: newColorScheme(),
 	}
 	f := &Formatter{
 		r: r,
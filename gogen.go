@@ -0,0 +1,254 @@
+package jsontools
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Words spelled in upper case in Go identifiers.
+var goInitialisms = map[string]bool{
+	"api": true, "cpu": true, "css": true, "dns": true, "html": true,
+	"http": true, "https": true, "id": true, "ip": true, "json": true,
+	"sql": true, "ttl": true, "uid": true, "uri": true, "url": true,
+	"uuid": true, "xml": true,
+}
+
+// splitWords splits a member name into words at punctuation and at
+// lower-to-upper case transitions.
+func splitWords(s string) []string {
+	var words []string
+	var word []rune
+	prev := rune(0)
+	for _, c := range s {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+			prev = 0
+			continue
+		}
+		if unicode.IsUpper(c) && (unicode.IsLower(prev) || unicode.IsDigit(prev)) && len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+		word = append(word, c)
+		prev = c
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// exportedName turns a member name into an exported identifier, like
+// "user_id" into "UserID". Names which don't start with an upper case
+// letter, such as "2fa" or "名前", get an "X" in front.
+func exportedName(name string) string {
+	var buf bytes.Buffer
+	for _, word := range splitWords(unquoteRaw(name)) {
+		if goInitialisms[strings.ToLower(word)] {
+			buf.WriteString(strings.ToUpper(word))
+			continue
+		}
+		r := []rune(word)
+		r[0] = unicode.ToUpper(r[0])
+		buf.WriteString(string(r))
+	}
+	s := buf.String()
+	if s == "" || !unicode.IsUpper([]rune(s)[0]) {
+		s = "X" + s
+	}
+	return s
+}
+
+// singular guesses the name of an element of a collection.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"),
+		strings.HasSuffix(name, "ches"), strings.HasSuffix(name, "shes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(name, "ss"), strings.HasSuffix(name, "us"):
+	case strings.HasSuffix(name, "s") && len(name) > 1:
+		return name[:len(name)-1]
+	}
+	return name + "Item"
+}
+
+// kinds returns the non-null types observed in sh, folding integers into
+// numbers when both were seen.
+func (sh *shape) kinds() []string {
+	var kinds []string
+	for _, t := range inferredTypes {
+		if t == "null" || sh.types[t] == 0 {
+			continue
+		}
+		if t == "integer" && sh.types["number"] > 0 {
+			continue
+		}
+		kinds = append(kinds, t)
+	}
+	return kinds
+}
+
+func (sh *shape) nullable() bool {
+	return sh.types["null"] > 0
+}
+
+// GoGenerator emits Go type definitions matching sample documents.
+type GoGenerator struct {
+	// Package is the package clause of the generated source.
+	Package string
+	// TypeName is the name of the type for the document root.
+	TypeName string
+
+	root *shape
+}
+
+func NewGoGenerator() *GoGenerator {
+	return &GoGenerator{
+		Package:  "main",
+		TypeName: "Root",
+		root:     newShape(),
+	}
+}
+
+// Add records the shape of a sample document.
+func (g *GoGenerator) Add(doc *decodeResult) {
+	g.root.observe(doc, doc.toplevel, 0)
+}
+
+type goStruct struct {
+	name string
+	sh   *shape
+}
+
+// typeNamer hands out unique type names.
+type typeNamer struct {
+	names map[string]bool
+}
+
+// typeName returns an unused type name based on name, or on the name of
+// its parent type and name if name is taken.
+func (t *typeNamer) typeName(name, parent string) string {
+	if t.names == nil {
+		t.names = make(map[string]bool)
+	}
+	candidates := []string{name, parent + name}
+	for _, c := range candidates {
+		if !t.names[c] {
+			t.names[c] = true
+			return c
+		}
+	}
+	for n := 2; ; n++ {
+		c := parent + name + strconv.Itoa(n)
+		if !t.names[c] {
+			t.names[c] = true
+			return c
+		}
+	}
+}
+
+// goWriter holds the state of a single run of GoGenerator.Generate.
+type goWriter struct {
+	typeNamer
+	structs []*goStruct
+}
+
+// goType returns the Go type for values of shape sh, queuing a struct
+// named after name for objects.
+func (w *goWriter) goType(sh *shape, name, parent string) string {
+	kinds := sh.kinds()
+	if len(kinds) != 1 {
+		return "interface{}"
+	}
+	var t string
+	switch kinds[0] {
+	case "object":
+		t = w.typeName(name, parent)
+		w.structs = append(w.structs, &goStruct{name: t, sh: sh})
+	case "array":
+		if sh.items.count == 0 {
+			return "[]interface{}"
+		}
+		return "[]" + w.goType(sh.items, singular(name), parent)
+	case "string":
+		t = "string"
+	case "integer":
+		t = "int64"
+		if sh.min < math.MinInt64 || sh.max > math.MaxInt64 {
+			t = "float64"
+		}
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	}
+	if sh.nullable() {
+		t = "*" + t
+	}
+	return t
+}
+
+func (w *goWriter) writeStruct(buf *bytes.Buffer, s *goStruct) {
+	fmt.Fprintf(buf, "type %s struct {\n", s.name)
+	fields := make(map[string]bool)
+	for _, member := range s.sh.propOrder {
+		child := s.sh.props[member]
+		field := exportedName(member)
+		for n := 2; fields[field]; n++ {
+			field = exportedName(member) + strconv.Itoa(n)
+		}
+		fields[field] = true
+
+		t := w.goType(child, exportedName(member), s.name)
+		tag := unquoteRaw(member)
+		if child.count < s.sh.types["object"] {
+			tag += ",omitempty"
+			// omitempty has no effect on struct values.
+			if kinds := child.kinds(); len(kinds) == 1 && kinds[0] == "object" && !child.nullable() {
+				t = "*" + t
+			}
+		}
+		tag = "json:" + strconv.Quote(tag)
+		if strings.ContainsRune(tag, '`') {
+			tag = strconv.Quote(tag)
+		} else {
+			tag = "`" + tag + "`"
+		}
+		fmt.Fprintf(buf, "\t%s %s %s\n", field, t, tag)
+	}
+	buf.WriteString("}\n\n")
+}
+
+// Generate writes the type definitions as gofmt-ed Go source.
+func (g *GoGenerator) Generate(out io.Writer) error {
+	w := &goWriter{}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n\n", g.Package)
+	if kinds := g.root.kinds(); len(kinds) != 1 || kinds[0] != "object" {
+		w.typeName(g.TypeName, "")
+		t := w.goType(g.root, g.TypeName, g.TypeName)
+		fmt.Fprintf(&buf, "type %s %s\n\n", g.TypeName, t)
+	} else {
+		w.goType(g.root, g.TypeName, "")
+	}
+	for i := 0; i < len(w.structs); i++ {
+		w.writeStruct(&buf, w.structs[i])
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(src)
+	return err
+}
@@ -0,0 +1,81 @@
+package jsontools
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportedName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"name":       "Name",
+		"user_id":    "UserID",
+		"createdAt":  "CreatedAt",
+		"avatar-url": "AvatarURL",
+		"HTTPStatus": "HTTPStatus",
+		"2fa":        "X2fa",
+		"":           "X",
+		"_private":   "Private",
+		"名前":         "X名前",
+		"_1":         "X1",
+	} {
+		assert.Equal(t, expected, exportedName(name))
+	}
+}
+
+func TestSingular(t *testing.T) {
+	for name, expected := range map[string]string{
+		"items":     "item",
+		"entries":   "entry",
+		"addresses": "address",
+		"boxes":     "box",
+		"matches":   "match",
+		"wishes":    "wish",
+		"status":    "statusItem",
+		"class":     "classItem",
+		"data":      "dataItem",
+	} {
+		assert.Equal(t, expected, singular(name), name)
+	}
+}
+
+func TestGoGenerator(t *testing.T) {
+	g := NewGoGenerator()
+	g.Add(mustDecode(t, `{"id": 1, "user_name": "a", "score": 1, "tags": ["x"],
+  "items": [{"sku": "a", "qty": 2}], "owner": {"id": 3}}`))
+	g.Add(mustDecode(t, `{"id": 2, "user_name": null, "score": 2.5, "tags": [],
+  "items": [{"sku": "b", "price": 1.5}], "extra": [1, "a"]}`))
+	var buf bytes.Buffer
+	assert.Nil(t, g.Generate(&buf))
+	assert.Equal(t, "package main\n\n"+
+		"type Root struct {\n"+
+		"\tID       int64         `json:\"id\"`\n"+
+		"\tUserName *string       `json:\"user_name\"`\n"+
+		"\tScore    float64       `json:\"score\"`\n"+
+		"\tTags     []string      `json:\"tags\"`\n"+
+		"\tItems    []Item        `json:\"items\"`\n"+
+		"\tOwner    *Owner        `json:\"owner,omitempty\"`\n"+
+		"\tExtra    []interface{} `json:\"extra,omitempty\"`\n"+
+		"}\n\n"+
+		"type Item struct {\n"+
+		"\tSku   string  `json:\"sku\"`\n"+
+		"\tQty   int64   `json:\"qty,omitempty\"`\n"+
+		"\tPrice float64 `json:\"price,omitempty\"`\n"+
+		"}\n\n"+
+		"type Owner struct {\n"+
+		"\tID int64 `json:\"id\"`\n"+
+		"}\n", buf.String())
+
+	g = NewGoGenerator()
+	g.Package = "api"
+	g.TypeName = "Events"
+	g.Add(mustDecode(t, `[{"at": 1}]`))
+	buf.Reset()
+	assert.Nil(t, g.Generate(&buf))
+	assert.Equal(t, "package api\n\n"+
+		"type Events []Event\n\n"+
+		"type Event struct {\n"+
+		"\tAt int64 `json:\"at\"`\n"+
+		"}\n", buf.String())
+}
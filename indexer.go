@@ -4,92 +4,371 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"regexp"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 )
 
 type IdentId int
 
+// IndexKind tells what an indexed identifier is.
+type IndexKind int
+
+const (
+	IndexMember IndexKind = iota
+	IndexString
+	IndexNumber
+	IndexLiteral
+)
+
+func (k IndexKind) String() string {
+	switch k {
+	case IndexMember:
+		return "member"
+	case IndexString:
+		return "string"
+	case IndexNumber:
+		return "number"
+	case IndexLiteral:
+		return "literal"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseIndexKinds parses a comma-separated list of kinds. Besides the
+// names of the kinds, it accepts "keys" for member names and "values"
+// for everything else.
+func ParseIndexKinds(s string) ([]IndexKind, error) {
+	var kinds []IndexKind
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(name) {
+		case "member", "keys":
+			kinds = append(kinds, IndexMember)
+		case "string":
+			kinds = append(kinds, IndexString)
+		case "number":
+			kinds = append(kinds, IndexNumber)
+		case "literal":
+			kinds = append(kinds, IndexLiteral)
+		case "values":
+			kinds = append(kinds, IndexString, IndexNumber, IndexLiteral)
+		default:
+			return nil, fmt.Errorf("unknown kind: %s", name)
+		}
+	}
+	return kinds, nil
+}
+
+// PathFormat selects a notation for paths.
+type PathFormat int
+
+const (
+	// PathPointer is a JSON Pointer, like /items/0/sku.
+	PathPointer PathFormat = iota
+	// PathJSONPath is a JSONPath expression, like $.items[0].sku.
+	PathJSONPath
+	// PathJq is a jq path, like .items[0].sku.
+	PathJq
+)
+
+func ParsePathFormat(s string) (PathFormat, error) {
+	switch s {
+	case "pointer":
+		return PathPointer, nil
+	case "jsonpath":
+		return PathJSONPath, nil
+	case "jq":
+		return PathJq, nil
+	}
+	return 0, fmt.Errorf("unknown path format: %s", s)
+}
+
 type IndexEntry struct {
 	Ident string
+	Kind  IndexKind
 	Path  string
 	Pos   ParserPosition
+	// File is the name of the file the entry was found in, or empty if
+	// the index was built from a reader.
+	File string
+
+	// location is where the entry is: the member itself for member
+	// names, and the value otherwise.
+	location valuePath
 }
 
 func (i *IndexEntry) String() string {
 	return fmt.Sprintf("%s: [%s]: %s", i.Pos.String(), i.Path, i.Ident)
 }
 
+// FormatPath returns the location of the entry in the notation f. The
+// location of a member name is that of the member.
+func (i *IndexEntry) FormatPath(f PathFormat) string {
+	switch f {
+	case PathJSONPath:
+		return i.location.JSONPath()
+	case PathJq:
+		return i.location.Jq()
+	}
+	return i.location.Pointer()
+}
+
+// pathElem is a step in the path of an index entry: the IdentId of a
+// member name, or an array index n stored as -n-1.
+type pathElem int
+
+func memberElem(id IdentId) pathElem {
+	return pathElem(id)
+}
+
+func indexElem(n int) pathElem {
+	return pathElem(-n - 1)
+}
+
+func (e pathElem) isIndex() bool {
+	return e < 0
+}
+
+func (e pathElem) index() int {
+	return int(-e - 1)
+}
+
+func (e pathElem) ident() IdentId {
+	return IdentId(e)
+}
+
 type indexEntryInternal struct {
-	Path []IdentId
+	// File is an index into Index.files.
+	File int
+	Kind IndexKind
+	Path []pathElem
 	Pos  ParserPosition
 }
 
+// indexedFile records a file an Index was built from, so that a saved
+// index can tell when it is out of date.
+type indexedFile struct {
+	name    string
+	size    int64
+	modTime int64
+}
+
 type Index struct {
-	idents   map[string]IdentId
-	identIds map[IdentId]string
-	idx      map[IdentId][]*indexEntryInternal
+	// names holds the identifiers, indexed by IdentId.
+	names []string
+	files []*indexedFile
+	idx   map[IdentId][]*indexEntryInternal
+	// file is set when the index was loaded by OpenIndex, in which case
+	// entries are read from it as they are needed.
+	file *indexFile
+	// mapped are the mappings the names refer to, which Close releases.
+	mapped []*MappedFile
+}
+
+func (i *Index) elemString(e pathElem) string {
+	if e.isIndex() {
+		return strconv.Itoa(e.index())
+	}
+	return i.names[e.ident()]
 }
 
-func (i *Index) buildPathString(path []IdentId) string {
+func (i *Index) buildPathString(path []pathElem) string {
 	l := len(path)
 	if l <= 0 {
 		return ""
 	}
 
 	var buf bytes.Buffer
-	for _, id := range path[:l-1] {
-		item := i.identIds[id]
-		buf.WriteString(item)
+	for _, e := range path[:l-1] {
+		buf.WriteString(i.elemString(e))
 		buf.WriteString(" -> ")
 	}
-	lastItem := i.identIds[path[l-1]]
-	buf.WriteString(lastItem)
+	buf.WriteString(i.elemString(path[l-1]))
 	return buf.String()
 }
 
 func (i *Index) newIndexEntry(id IdentId, e *indexEntryInternal) *IndexEntry {
 	return &IndexEntry{
-		Ident: i.identIds[id],
-		Path:  i.buildPathString(e.Path),
-		Pos:   e.Pos,
+		Ident:    i.names[id],
+		Kind:     e.Kind,
+		Path:     i.buildPathString(e.Path),
+		Pos:      e.Pos,
+		File:     i.files[e.File].name,
+		location: i.entryPath(id, e),
 	}
 }
 
-func (i *Index) id(s string) IdentId {
-	id, ok := i.idents[s]
-	if !ok {
-		panic(fmt.Errorf("No ID for %s", s))
+// Files returns the names of the files in the index.
+func (i *Index) Files() []string {
+	var names []string
+	for _, f := range i.files {
+		names = append(names, f.name)
 	}
-	return id
+	return names
 }
 
-func (i *Index) Match(q string) []*IndexEntry {
-	var results []*IndexEntry
-	re, err := regexp.Compile(q)
-	if err != nil {
-		return results
+// entries returns the occurrences of the identifier id.
+func (i *Index) entries(id IdentId) []*indexEntryInternal {
+	if i.file != nil {
+		return i.file.entries(id)
+	}
+	return i.idx[id]
+}
+
+func hasKind(kinds []IndexKind, kind IndexKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
 	}
-	for ident := range i.idents {
-		if !re.MatchString(ident) {
+	return false
+}
+
+type indexMatch struct {
+	id      IdentId
+	entry   *indexEntryInternal
+	quality matchQuality
+}
+
+// entryPath returns the location of e, an entry for id.
+func (i *Index) entryPath(id IdentId, e *indexEntryInternal) valuePath {
+	path := make(valuePath, 0, len(e.Path)+1)
+	for _, p := range e.Path {
+		if p.isIndex() {
+			path = append(path, indexSegment(p.index()))
+		} else {
+			path = append(path, memberSegment(i.names[p.ident()]))
+		}
+	}
+	if e.Kind == IndexMember {
+		path = append(path, memberSegment(i.names[id]))
+	}
+	return path
+}
+
+// find returns the entries selected by query, in no particular order.
+func (i *Index) find(query queryNode, kinds []IndexKind) []indexMatch {
+	var matches []indexMatch
+	for n, ident := range i.names {
+		if !query.mayMatch(ident) {
 			continue
 		}
-		id := i.id(ident)
-		for _, e := range i.idx[id] {
-			results = append(results, i.newIndexEntry(id, e))
+		id := IdentId(n)
+		quality := query.quality(ident)
+		for _, e := range i.entries(id) {
+			if len(kinds) > 0 && !hasKind(kinds, e.Kind) {
+				continue
+			}
+			qe := &queryEntry{
+				ident: ident,
+				kind:  e.Kind,
+				path: func() valuePath {
+					return i.entryPath(id, e)
+				},
+			}
+			if !query.eval(qe) {
+				continue
+			}
+			matches = append(matches, indexMatch{id, e, quality})
 		}
 	}
+	return matches
+}
+
+// before reports whether a comes before b in the document order of the
+// index.
+func (a *indexMatch) before(b *indexMatch) bool {
+	if a.entry.File != b.entry.File {
+		return a.entry.File < b.entry.File
+	}
+	return a.entry.Pos.Offset < b.entry.Pos.Offset
+}
+
+func (i *Index) newIndexEntries(matches []indexMatch) []*IndexEntry {
+	var results []*IndexEntry
+	for _, m := range matches {
+		results = append(results, i.newIndexEntry(m.id, m.entry))
+	}
 	return results
 }
 
+// Match returns the entries selected by the query q, grouped by file and
+// in document order. If kinds are given, only entries of those kinds are
+// returned. See query.go for the query language.
+func (i *Index) Match(q string, kinds ...IndexKind) ([]*IndexEntry, error) {
+	query, err := parseQuery(q, false)
+	if err != nil {
+		return nil, err
+	}
+	matches := i.find(query, kinds)
+	sort.Slice(matches, func(a, b int) bool {
+		return matches[a].before(&matches[b])
+	})
+	return i.newIndexEntries(matches), nil
+}
+
+// SearchOptions control Index.Search.
+type SearchOptions struct {
+	// Kinds restricts the results to entries of these kinds.
+	Kinds []IndexKind
+	// Fuzzy also matches plain terms to identifiers a few typos away.
+	Fuzzy bool
+	// Offset is the number of results to skip, and Limit, if positive,
+	// the maximum number to return.
+	Offset int
+	Limit  int
+}
+
+// SearchResult is a page of search results.
+type SearchResult struct {
+	Entries []*IndexEntry
+	// Total is the number of results on all pages.
+	Total int
+}
+
+// Search is like Match but ranks the results: exact matches come first,
+// followed by prefix, substring and fuzzy matches, each in document
+// order.
+func (i *Index) Search(q string, opts *SearchOptions) (*SearchResult, error) {
+	query, err := parseQuery(q, opts.Fuzzy)
+	if err != nil {
+		return nil, err
+	}
+	matches := i.find(query, opts.Kinds)
+	sort.Slice(matches, func(a, b int) bool {
+		if matches[a].quality != matches[b].quality {
+			return matches[a].quality > matches[b].quality
+		}
+		return matches[a].before(&matches[b])
+	})
+	result := &SearchResult{Total: len(matches)}
+	if opts.Offset >= len(matches) {
+		return result, nil
+	}
+	matches = matches[opts.Offset:]
+	if opts.Limit > 0 && opts.Limit < len(matches) {
+		matches = matches[:opts.Limit]
+	}
+	result.Entries = i.newIndexEntries(matches)
+	return result, nil
+}
+
 type indexerClient struct {
 	ParserClientBase
 
 	currentIdentId IdentId
 	idents         map[string]IdentId
-	path           []IdentId
-	arrayIndex     int
-	parser         *Parser
+	path           []pathElem
+	// arrayIndices holds the index of the next element of each array
+	// being parsed.
+	arrayIndices []int
+	parser       *Parser
 
 	idx map[IdentId][]*indexEntryInternal
 }
@@ -108,31 +387,35 @@ func (i *indexerClient) index(id IdentId, e *indexEntryInternal) {
 	i.idx[id] = append(i.idx[id], e)
 }
 
-func (i *indexerClient) indexIdent(s string) {
+func (i *indexerClient) indexIdent(s string, kind IndexKind) {
 	entry := &indexEntryInternal{
-		Path: make([]IdentId, len(i.path)),
-		Pos:  i.parser.CurrentPos(),
+		Kind: kind,
+		Path: make([]pathElem, len(i.path)),
+		Pos:  i.parser.TokenPos(),
 	}
 	copy(entry.Path, i.path)
 	id := i.idFor(s)
 	i.index(id, entry)
 }
 
-func (i *indexerClient) PushPath(s string) {
-	id := i.idFor(s)
-	i.path = append(i.path, id)
+func (i *indexerClient) pushMember(s string) {
+	i.path = append(i.path, memberElem(i.idFor(s)))
+}
+
+func (i *indexerClient) pushIndex(n int) {
+	i.path = append(i.path, indexElem(n))
 }
 
-func (i *indexerClient) PopPath() {
+func (i *indexerClient) popPath() {
 	i.path = i.path[:len(i.path)-1]
 }
 
 func (i *indexerClient) AddMember(s string) {
-	i.indexIdent(s)
+	i.indexIdent(s, IndexMember)
 }
 
 func (i *indexerClient) AddString(s string) {
-	i.indexIdent(s)
+	i.indexIdent(s, IndexString)
 }
 
 // ParserClient implementations
@@ -140,24 +423,29 @@ func (i *indexerClient) AddString(s string) {
 func (i *indexerClient) StartMember(s string) {
 	s = s[1 : len(s)-1]
 	i.AddMember(s)
-	i.PushPath(s)
+	i.pushMember(s)
 }
 
 func (i *indexerClient) EndMember(HasNext) {
-	i.PopPath()
+	i.popPath()
 }
 
 func (i *indexerClient) StartArray() {
-	i.arrayIndex = 0
+	i.arrayIndices = append(i.arrayIndices, 0)
+}
+
+func (i *indexerClient) EndArray() {
+	i.arrayIndices = i.arrayIndices[:len(i.arrayIndices)-1]
 }
 
 func (i *indexerClient) StartValue() {
-	i.PushPath(strconv.Itoa(i.arrayIndex))
-	i.arrayIndex += 1
+	top := len(i.arrayIndices) - 1
+	i.pushIndex(i.arrayIndices[top])
+	i.arrayIndices[top]++
 }
 
 func (i *indexerClient) EndValue(HasNext) {
-	i.PopPath()
+	i.popPath()
 }
 
 func (i *indexerClient) StringValue(s string) {
@@ -165,6 +453,14 @@ func (i *indexerClient) StringValue(s string) {
 	i.AddString(s)
 }
 
+func (i *indexerClient) NumberValue(s string) {
+	i.indexIdent(s, IndexNumber)
+}
+
+func (i *indexerClient) LiteralValue(l Literal) {
+	i.indexIdent(l.String(), IndexLiteral)
+}
+
 type Indexer struct {
 	parser *Parser
 	client *indexerClient
@@ -174,26 +470,179 @@ func (i *Indexer) CreateIndex() (*Index, error) {
 	if err := i.parser.Parse(); err != nil {
 		return nil, err
 	}
-	identIds := make(map[IdentId]string)
+	names := make([]string, len(i.client.idents))
 	for ident, id := range i.client.idents {
-		identIds[id] = ident
+		names[id] = ident
 	}
 	return &Index{
-		idents:   i.client.idents,
-		identIds: identIds,
-		idx:      i.client.idx,
+		names: names,
+		files: []*indexedFile{{}},
+		idx:   i.client.idx,
 	}, nil
 }
 
+// indexNamedFile indexes the named file.
+func indexNamedFile(name string) (*Index, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// Pipes and such can't be mapped, but can still be read.
+	indexer := NewIndexer(f)
+	mapped, err := MapFile(f)
+	if err == nil {
+		indexer = mapped.NewIndexer()
+	}
+	index, err := indexer.CreateIndex()
+	if err != nil {
+		if mapped != nil {
+			mapped.Close()
+		}
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	if mapped != nil {
+		index.mapped = []*MappedFile{mapped}
+	}
+	index.files[0] = &indexedFile{
+		name:    name,
+		size:    info.Size(),
+		modTime: info.ModTime().UnixNano(),
+	}
+	return index, nil
+}
+
+// IndexFiles indexes the named files in parallel and returns a single
+// index covering all of them.
+func IndexFiles(names []string) (*Index, error) {
+	parts := make([]*Index, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.NumCPU())
+	for n, name := range names {
+		wg.Add(1)
+		go func(n int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			parts[n], errs[n] = indexNamedFile(name)
+			<-sem
+		}(n, name)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			for _, part := range parts {
+				if part != nil {
+					part.Close()
+				}
+			}
+			return nil, err
+		}
+	}
+	return mergeIndexes(parts), nil
+}
+
+// mergeIndexes combines indexes built from different files. The entries
+// of the parts are reused.
+func mergeIndexes(parts []*Index) *Index {
+	result := &Index{
+		idx: make(map[IdentId][]*indexEntryInternal),
+	}
+	ids := make(map[string]IdentId)
+	for _, part := range parts {
+		remap := make([]IdentId, len(part.names))
+		for n, name := range part.names {
+			id, ok := ids[name]
+			if !ok {
+				id = IdentId(len(result.names))
+				ids[name] = id
+				result.names = append(result.names, name)
+			}
+			remap[n] = id
+		}
+		fileBase := len(result.files)
+		result.files = append(result.files, part.files...)
+		result.mapped = append(result.mapped, part.mapped...)
+		for n := range part.names {
+			id := remap[n]
+			for _, e := range part.entries(IdentId(n)) {
+				for k, p := range e.Path {
+					if !p.isIndex() {
+						e.Path[k] = memberElem(remap[p.ident()])
+					}
+				}
+				e.File += fileBase
+				result.idx[id] = append(result.idx[id], e)
+			}
+		}
+	}
+	return result
+}
+
+// ExpandPaths expands glob patterns and walks directories, returning the
+// names of the JSON files they refer to. Names which aren't patterns or
+// directories are returned as they are. A file which several arguments
+// refer to is only returned the first time.
+func ExpandPaths(patterns []string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if clean := filepath.Clean(name); !seen[clean] {
+			seen[clean] = true
+			names = append(names, name)
+		}
+	}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			matches = []string{pattern}
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || !info.IsDir() {
+				add(match)
+				continue
+			}
+			err = filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.Type().IsRegular() && filepath.Ext(path) == ".json" {
+					add(path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return names, nil
+}
+
+// NewIndexer returns an Indexer for the document in r.
 func NewIndexer(r io.Reader) *Indexer {
-	client := &indexerClient{
+	client := newIndexerClient()
+	return newIndexer(NewParser(r, client), client)
+}
+
+func newIndexerClient() *indexerClient {
+	return &indexerClient{
 		currentIdentId: 0,
 		idents:         make(map[string]IdentId),
-		path:           make([]IdentId, 0),
-		arrayIndex:     0,
+		path:           make([]pathElem, 0),
 		idx:            make(map[IdentId][]*indexEntryInternal),
 	}
-	parser := NewParser(r, client)
+}
+
+func newIndexer(parser *Parser, client *indexerClient) *Indexer {
 	client.parser = parser
 	return &Indexer{
 		parser: parser,
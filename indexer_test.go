@@ -0,0 +1,148 @@
+package jsontools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func entryStrings(entries []*IndexEntry) []string {
+	var s []string
+	for _, e := range entries {
+		s = append(s, e.String())
+	}
+	return s
+}
+
+func mustMatch(t *testing.T, index *Index, q string, kinds ...IndexKind) []*IndexEntry {
+	entries, err := index.Match(q, kinds...)
+	assert.Nil(t, err, q)
+	return entries
+}
+
+func TestIndexMatch(t *testing.T) {
+	index, err := NewIndexer(strings.NewReader(`{"name": "apple",
+ "tags": ["red", "fruit"]}`)).CreateIndex()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"1:2: []: name",
+		"1:10: [name]: apple",
+	}, entryStrings(mustMatch(t, index, "/^(name|apple)$/")))
+	assert.Equal(t, []string{"2:18: [tags -> 1]: fruit"}, entryStrings(mustMatch(t, index, "/fr/")))
+	assert.Equal(t, 35, mustMatch(t, index, "/fr/")[0].Pos.Offset)
+}
+
+func TestIndexPaths(t *testing.T) {
+	index, err := NewIndexer(strings.NewReader(
+		`{"a": [[1, 2], ["x"], {"0": "y", "b c": ["z"]}]}`)).CreateIndex()
+	assert.Nil(t, err)
+	var paths []string
+	for _, e := range mustMatch(t, index, "/^[xyz]$/ OR key:b") {
+		paths = append(paths, e.Path+" "+e.FormatPath(PathPointer)+" "+
+			e.FormatPath(PathJSONPath)+" "+e.FormatPath(PathJq))
+	}
+	assert.Equal(t, []string{
+		`a -> 1 -> 0 /a/1/0 $.a[1][0] .a[1][0]`,
+		`a -> 2 -> 0 /a/2/0 $.a[2]["0"] .a[2]["0"]`,
+		`a -> 2 /a/2/b c $.a[2]["b c"] .a[2]["b c"]`,
+		`a -> 2 -> b c -> 0 /a/2/b c/0 $.a[2]["b c"][0] .a[2]["b c"][0]`,
+	}, paths)
+	assert.Equal(t, []string{"0", "y"}, entryIdents(mustMatch(t, index, "path:a[2].0")))
+	assert.Equal(t, []string{"x"}, entryIdents(mustMatch(t, index, "path:a[1][0] OR path:a.1.0")))
+
+	entries := mustMatch(t, index, "kind:number")
+	assert.Equal(t, "/a/0/1", entries[1].FormatPath(PathPointer))
+	root := mustMatch(t, index, "key:a")
+	assert.Equal(t, ".a", root[0].FormatPath(PathJq))
+	_, err = ParsePathFormat("xpath")
+	assert.NotNil(t, err)
+}
+
+func TestIndexKinds(t *testing.T) {
+	index, err := NewIndexer(strings.NewReader(
+		`{"1042": 1042, "ok": true, "ref": "1042", "n": null, "x": -1.5}`)).CreateIndex()
+	assert.Nil(t, err)
+	var kinds []string
+	for _, e := range mustMatch(t, index, "/^1042$/") {
+		kinds = append(kinds, e.Kind.String())
+	}
+	assert.Equal(t, []string{"member", "number", "string"}, kinds)
+	assert.Equal(t, 1, len(mustMatch(t, index, "/^1042$/", IndexNumber)))
+	assert.Equal(t, 1, len(mustMatch(t, index, "/^1042$/", IndexMember)))
+
+	values, err := ParseIndexKinds("values")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(mustMatch(t, index, "/^1042$/", values...)))
+	assert.Equal(t, []string{"true", "null"}, entryIdents(mustMatch(t, index, "/./", IndexLiteral)))
+	assert.Equal(t, []string{"-1.5"}, entryIdents(mustMatch(t, index, `/\./`, IndexNumber)))
+	_, err = ParseIndexKinds("keys,bogus")
+	assert.NotNil(t, err)
+}
+
+func entryIdents(entries []*IndexEntry) []string {
+	var s []string
+	for _, e := range entries {
+		s = append(s, e.Ident)
+	}
+	return s
+}
+
+func TestIndexFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "doc.json")
+	assert.Nil(t, os.WriteFile(src, []byte(`{"a": ["x", {"b": "x"}], "": "y"}`), 0644))
+	index, err := IndexFiles([]string{src})
+	assert.Nil(t, err)
+	name := filepath.Join(dir, "doc.json.idx")
+	assert.Nil(t, index.Save(name))
+
+	loaded, err := OpenIndex(name)
+	assert.Nil(t, err)
+	assert.Equal(t, index.names, loaded.names)
+	assert.Equal(t, []string{src}, loaded.Files())
+	for _, q := range []string{"x", "/^$/", "b OR y", "path:a[1]"} {
+		assert.Equal(t, entryStrings(mustMatch(t, index, q)), entryStrings(mustMatch(t, loaded, q)), q)
+	}
+	assert.Equal(t, 2, len(mustMatch(t, loaded, "/^(a|x)$/", IndexString)))
+	assert.Equal(t, 2, len(mustMatch(t, loaded, "/^x$/")))
+	assert.Nil(t, loaded.Close())
+
+	assert.Nil(t, os.WriteFile(src, []byte(`{"a": 1}`), 0644))
+	_, err = OpenIndex(name)
+	assert.Equal(t, ErrStaleIndex, err)
+
+	_, err = OpenIndex(src)
+	assert.NotNil(t, err)
+}
+
+func TestIndexFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	files := map[string]string{
+		"a.json":     `{"id": "x1", "tags": ["x2"]}`,
+		"sub/b.json": `[{"id": "x3"}]`,
+		"notes.txt":  `not json`,
+	}
+	for name, content := range files {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	names, err := ExpandPaths([]string{dir, filepath.Join(dir, "*.json")})
+	assert.Nil(t, err)
+	a := filepath.Join(dir, "a.json")
+	b := filepath.Join(dir, "sub", "b.json")
+	assert.Equal(t, []string{a, b}, names)
+
+	index, err := IndexFiles(names)
+	assert.Nil(t, err)
+	var found []string
+	for _, e := range mustMatch(t, index, "/^(id|x.)$/") {
+		found = append(found, e.File+" "+e.Ident)
+	}
+	assert.Equal(t, []string{a + " id", a + " x1", a + " x2", b + " id", b + " x3"}, found)
+
+	_, err = IndexFiles([]string{a, filepath.Join(dir, "notes.txt")})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,314 @@
+package jsontools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+// An index file starts with a header:
+//
+//	magic        "JSONIDX4"
+//	numIdents    uint64
+//	tablesOffset uint64
+//
+// followed by the files the index was built from: a uvarint count and,
+// for each file, its name as a uvarint length and bytes, its size as a
+// uvarint and its modification time in nanoseconds as a varint. Then, at
+// tablesOffset, come two tables of numIdents+1 offsets, delimiting the
+// identifiers and the entry lists of each identifier, and then the
+// identifiers and entry lists themselves. Offsets are from the start of
+// the file, and the header and offsets are little-endian. Each entry is a
+// sequence of uvarints: file, kind, line, column, byte offset and path
+// length, followed by the path as varints, with member names as their
+// IdentIds and array indices n as -n-1.
+
+const indexMagic = "JSONIDX4"
+
+const indexHeaderSize = 24
+
+// ErrStaleIndex is returned by OpenIndex when one of the indexed files
+// has changed since the index was saved.
+var ErrStaleIndex = errors.New("index is out of date")
+
+var errCorruptIndex = errors.New("index file is corrupt")
+
+type indexFile struct {
+	data         []byte
+	numIdents    int
+	numFiles     int
+	tablesOffset int
+}
+
+func (f *indexFile) offset(table int, n int) uint64 {
+	pos := f.tablesOffset + (table*(f.numIdents+1)+n)*8
+	return binary.LittleEndian.Uint64(f.data[pos:])
+}
+
+// validate checks that the offset tables lie within the file.
+func (f *indexFile) validate() error {
+	tablesEnd := uint64(f.tablesOffset + 2*(f.numIdents+1)*8)
+	if uint64(len(f.data)) < tablesEnd {
+		return errors.New("index file is truncated")
+	}
+	prev := tablesEnd
+	for table := 0; table < 2; table++ {
+		for n := 0; n <= f.numIdents; n++ {
+			offset := f.offset(table, n)
+			if offset < prev || offset > uint64(len(f.data)) {
+				return errCorruptIndex
+			}
+			prev = offset
+		}
+	}
+	return nil
+}
+
+// files decodes the file table.
+func (f *indexFile) files() ([]*indexedFile, error) {
+	b := f.data[indexHeaderSize:f.tablesOffset]
+	failed := false
+	next := func() uint64 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			failed = true
+			return 0
+		}
+		b = b[n:]
+		return v
+	}
+	count := next()
+	if count > uint64(len(b)) {
+		return nil, errCorruptIndex
+	}
+	files := make([]*indexedFile, count)
+	for n := range files {
+		length := next()
+		if failed || length > uint64(len(b)) {
+			return nil, errCorruptIndex
+		}
+		file := &indexedFile{name: string(b[:length])}
+		b = b[length:]
+		file.size = int64(next())
+		modTime, k := binary.Varint(b)
+		if failed || k <= 0 {
+			return nil, errCorruptIndex
+		}
+		b = b[k:]
+		file.modTime = modTime
+		files[n] = file
+	}
+	return files, nil
+}
+
+func (f *indexFile) name(n int) string {
+	start, end := f.offset(0, n), f.offset(0, n+1)
+	if start == end {
+		return ""
+	}
+	return unsafe.String(&f.data[start], int(end-start))
+}
+
+func (f *indexFile) entries(id IdentId) []*indexEntryInternal {
+	b := f.data[f.offset(1, int(id)):f.offset(1, int(id)+1)]
+	next := func() int {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			b = nil
+			return 0
+		}
+		b = b[n:]
+		return int(v)
+	}
+	// Entries referring to files or identifiers which don't exist are
+	// dropped, along with the rest of the list.
+	var entries []*indexEntryInternal
+	for len(b) > 0 {
+		e := &indexEntryInternal{}
+		e.File = next()
+		e.Kind = IndexKind(next())
+		e.Pos.Line = next()
+		e.Pos.Column = next()
+		e.Pos.Offset = next()
+		pathLen := next()
+		if e.File >= f.numFiles || pathLen > len(b) {
+			break
+		}
+		e.Path = make([]pathElem, pathLen)
+		for n := range e.Path {
+			v, k := binary.Varint(b)
+			if k <= 0 || v >= int64(f.numIdents) {
+				return entries
+			}
+			b = b[k:]
+			e.Path[n] = pathElem(v)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Save writes the index to name, along with the sizes and modification
+// times of the indexed files.
+func (i *Index) Save(name string) error {
+	numIdents := len(i.names)
+	var files, idents, entries bytes.Buffer
+	var varint [binary.MaxVarintLen64]byte
+	put := func(buf *bytes.Buffer, v uint64) {
+		n := binary.PutUvarint(varint[:], v)
+		buf.Write(varint[:n])
+	}
+	put(&files, uint64(len(i.files)))
+	for _, f := range i.files {
+		put(&files, uint64(len(f.name)))
+		files.WriteString(f.name)
+		put(&files, uint64(f.size))
+		n := binary.PutVarint(varint[:], f.modTime)
+		files.Write(varint[:n])
+	}
+
+	identOffsets := make([]uint64, 0, numIdents+1)
+	entryOffsets := make([]uint64, 0, numIdents+1)
+	for n, ident := range i.names {
+		identOffsets = append(identOffsets, uint64(idents.Len()))
+		idents.WriteString(ident)
+		entryOffsets = append(entryOffsets, uint64(entries.Len()))
+		for _, e := range i.entries(IdentId(n)) {
+			put(&entries, uint64(e.File))
+			put(&entries, uint64(e.Kind))
+			put(&entries, uint64(e.Pos.Line))
+			put(&entries, uint64(e.Pos.Column))
+			put(&entries, uint64(e.Pos.Offset))
+			put(&entries, uint64(len(e.Path)))
+			for _, p := range e.Path {
+				n := binary.PutVarint(varint[:], int64(p))
+				entries.Write(varint[:n])
+			}
+		}
+	}
+	identOffsets = append(identOffsets, uint64(idents.Len()))
+	entryOffsets = append(entryOffsets, uint64(entries.Len()))
+
+	var header bytes.Buffer
+	tablesOffset := uint64(indexHeaderSize + files.Len())
+	header.WriteString(indexMagic)
+	binary.Write(&header, binary.LittleEndian, uint64(numIdents))
+	binary.Write(&header, binary.LittleEndian, tablesOffset)
+	var tables bytes.Buffer
+	base := tablesOffset + uint64(2*(numIdents+1)*8)
+	for _, offset := range identOffsets {
+		binary.Write(&tables, binary.LittleEndian, base+offset)
+	}
+	base += uint64(idents.Len())
+	for _, offset := range entryOffsets {
+		binary.Write(&tables, binary.LittleEndian, base+offset)
+	}
+
+	// Write to a temporary file first so that a failed save doesn't
+	// leave a truncated index behind.
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	for _, b := range []*bytes.Buffer{&header, &files, &tables, &idents, &entries} {
+		if _, err := b.WriteTo(f); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// OpenIndex loads the index saved in name, mapping it into memory where
+// possible, in which case it stays mapped until Close. It returns
+// ErrStaleIndex if any of the indexed files has changed since the index
+// was saved.
+func OpenIndex(name string) (*Index, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var data []byte
+	mapped, err := MapFile(f)
+	if err == nil {
+		data = mapped.Bytes()
+	} else if data, err = os.ReadFile(name); err != nil {
+		return nil, err
+	}
+	index, err := openIndex(name, data)
+	if err != nil {
+		if mapped != nil {
+			mapped.Close()
+		}
+		return nil, err
+	}
+	if mapped != nil {
+		index.mapped = []*MappedFile{mapped}
+	}
+	return index, nil
+}
+
+// Close releases the files mapped to build or load the index. The index
+// must not be used afterwards.
+func (i *Index) Close() error {
+	var err error
+	for _, m := range i.mapped {
+		if e := m.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func openIndex(name string, data []byte) (*Index, error) {
+	if len(data) < indexHeaderSize || string(data[:len(indexMagic)]) != indexMagic {
+		return nil, fmt.Errorf("%s: not an index file", name)
+	}
+	numIdents := binary.LittleEndian.Uint64(data[8:])
+	tablesOffset := binary.LittleEndian.Uint64(data[16:])
+	if numIdents > uint64(len(data)) || tablesOffset < indexHeaderSize || tablesOffset > uint64(len(data)) {
+		return nil, fmt.Errorf("%s: %s", name, errCorruptIndex)
+	}
+	file := &indexFile{
+		data:         data,
+		numIdents:    int(numIdents),
+		tablesOffset: int(tablesOffset),
+	}
+	if err := file.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	files, err := file.files()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	file.numFiles = len(files)
+	for _, f := range files {
+		if f.name == "" {
+			// Built from a reader.
+			continue
+		}
+		info, err := os.Stat(f.name)
+		if err != nil || info.Size() != f.size || info.ModTime().UnixNano() != f.modTime {
+			return nil, ErrStaleIndex
+		}
+	}
+	names := make([]string, file.numIdents)
+	for n := range names {
+		names[n] = file.name(n)
+	}
+	return &Index{
+		names: names,
+		files: files,
+		file:  file,
+	}, nil
+}
@@ -0,0 +1,246 @@
+package jsontools
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const defaultMaxEnum = 10
+
+// shape accumulates what was observed at one path across documents.
+type shape struct {
+	count int
+	types map[string]int
+	// objects
+	props     map[string]*shape
+	propOrder []string
+	// arrays
+	items              *shape
+	minItems, maxItems int
+	// strings, or nil once there are too many distinct ones
+	strings map[string]int
+	// numbers
+	min, max float64
+}
+
+func newShape() *shape {
+	return &shape{
+		types:    make(map[string]int),
+		strings:  make(map[string]int),
+		minItems: math.MaxInt32,
+		min:      math.Inf(1),
+		max:      math.Inf(-1),
+	}
+}
+
+// SchemaInferrer builds a JSON Schema describing sample documents.
+type SchemaInferrer struct {
+	// MaxEnum is the largest number of distinct strings at a path that
+	// is still reported as an enum.
+	MaxEnum int
+
+	root          *shape
+	docs          int
+	numObjects    int64
+	numArrays     int64
+	numPrimitives int64
+}
+
+func NewSchemaInferrer() *SchemaInferrer {
+	return &SchemaInferrer{
+		MaxEnum: defaultMaxEnum,
+		root:    newShape(),
+	}
+}
+
+// Add records the shape of doc.
+func (s *SchemaInferrer) Add(doc *decodeResult) {
+	s.docs++
+	s.numObjects += doc.numObjects
+	s.numArrays += doc.numArrays
+	s.numPrimitives += doc.numPrimitives
+	s.root.observe(doc, doc.toplevel, s.MaxEnum)
+}
+
+// observe records v, which belongs to doc. At most maxEnum distinct
+// strings are remembered.
+func (sh *shape) observe(doc *decodeResult, v jsonValue, maxEnum int) {
+	sh.count++
+	switch value := v.(type) {
+	case *objectValue:
+		sh.types["object"]++
+		if sh.props == nil {
+			sh.props = make(map[string]*shape)
+		}
+		for _, id := range value.keys {
+			name := doc.str(id)
+			child, ok := sh.props[name]
+			if !ok {
+				child = newShape()
+				sh.props[name] = child
+				sh.propOrder = append(sh.propOrder, name)
+			}
+			child.observe(doc, value.props[id], maxEnum)
+		}
+	case *arrayValue:
+		sh.types["array"]++
+		if sh.items == nil {
+			sh.items = newShape()
+		}
+		n := len(value.elems)
+		if n < sh.minItems {
+			sh.minItems = n
+		}
+		if n > sh.maxItems {
+			sh.maxItems = n
+		}
+		for _, e := range value.elems {
+			sh.items.observe(doc, e, maxEnum)
+		}
+	case *stringValue:
+		sh.types["string"]++
+		if sh.strings != nil {
+			sh.strings[doc.str(value.id)]++
+			if len(sh.strings) > maxEnum {
+				sh.strings = nil
+			}
+		}
+	case *numberValue:
+		if value.integer {
+			sh.types["integer"]++
+		} else {
+			sh.types["number"]++
+		}
+		sh.min = math.Min(sh.min, value.value)
+		sh.max = math.Max(sh.max, value.value)
+	case *literalValue:
+		if value.value == Null {
+			sh.types["null"]++
+		} else {
+			sh.types["boolean"]++
+		}
+	}
+}
+
+// schemaBuilder creates the values of the inferred schema document.
+type schemaBuilder struct {
+	doc *decodeResult
+}
+
+func (b *schemaBuilder) object() *objectValue {
+	return &objectValue{props: make(map[uint]jsonValue)}
+}
+
+func (b *schemaBuilder) set(obj *objectValue, name string, v jsonValue) {
+	obj.set(b.doc.intern(name), v)
+}
+
+func (b *schemaBuilder) str(s string) *stringValue {
+	return &stringValue{b.doc.intern(s)}
+}
+
+func (b *schemaBuilder) num(n float64) *numberValue {
+	return &numberValue{value: n, integer: n == math.Trunc(n)}
+}
+
+// Types in the order they are listed in "type".
+var inferredTypes = []string{"object", "array", "string", "integer", "number", "boolean", "null"}
+
+func (s *SchemaInferrer) build(b *schemaBuilder, sh *shape) *objectValue {
+	schema := b.object()
+	types := &arrayValue{}
+	for _, t := range inferredTypes {
+		// Integers are reported as numbers when both were seen.
+		if t == "integer" && sh.types["number"] > 0 {
+			continue
+		}
+		if sh.types[t] > 0 {
+			types.elems = append(types.elems, b.str(t))
+		}
+	}
+	if len(types.elems) == 1 {
+		b.set(schema, "type", types.elems[0])
+	} else if len(types.elems) > 1 {
+		b.set(schema, "type", types)
+	}
+
+	if sh.props != nil {
+		properties := b.object()
+		required := &arrayValue{}
+		for _, name := range sh.propOrder {
+			child := sh.props[name]
+			b.set(properties, name, s.build(b, child))
+			if child.count == sh.types["object"] {
+				required.elems = append(required.elems, b.str(name))
+			}
+		}
+		b.set(schema, "properties", properties)
+		if len(required.elems) > 0 {
+			b.set(schema, "required", required)
+		}
+	}
+	if sh.items != nil {
+		if sh.items.count > 0 {
+			b.set(schema, "items", s.build(b, sh.items))
+		}
+		b.set(schema, "minItems", b.num(float64(sh.minItems)))
+		b.set(schema, "maxItems", b.num(float64(sh.maxItems)))
+	}
+	if sh.types["integer"] > 0 || sh.types["number"] > 0 {
+		b.set(schema, "minimum", b.num(sh.min))
+		b.set(schema, "maximum", b.num(sh.max))
+	}
+	if enum := s.enum(b, sh); enum != nil {
+		b.set(schema, "enum", enum)
+	}
+	b.set(schema, "x-count", b.num(float64(sh.count)))
+	return schema
+}
+
+// enum returns the observed strings if they look like a fixed set: few
+// distinct values, each seen more than once on average.
+func (s *SchemaInferrer) enum(b *schemaBuilder, sh *shape) *arrayValue {
+	stringCount := sh.types["string"]
+	if sh.strings == nil || stringCount == 0 || stringCount <= len(sh.strings) {
+		return nil
+	}
+	if stringCount+sh.types["null"] != sh.count {
+		return nil
+	}
+	enum := &arrayValue{}
+	for _, v := range sortedKeys(sh.strings) {
+		enum.elems = append(enum.elems, b.str(v))
+	}
+	if sh.types["null"] > 0 {
+		enum.elems = append(enum.elems, &literalValue{Null})
+	}
+	return enum
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Schema returns the inferred schema as a document.
+func (s *SchemaInferrer) Schema() *decodeResult {
+	b := &schemaBuilder{
+		doc: &decodeResult{symtab: make(symbolTable)},
+	}
+	schema := b.object()
+	b.set(schema, "$schema", b.str("https://json-schema.org/draft/2020-12/schema"))
+	b.set(schema, "$comment", b.str(fmt.Sprintf(
+		"inferred from %d documents: objects = %d, arrays = %d, primitives = %d",
+		s.docs, s.numObjects, s.numArrays, s.numPrimitives)))
+	root := s.build(b, s.root)
+	for _, id := range root.keys {
+		schema.set(id, root.props[id])
+	}
+	b.doc.toplevel = schema
+	return b.doc
+}
@@ -0,0 +1,69 @@
+package jsontools
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentReader(t *testing.T) {
+	reader := NewDocumentReader(strings.NewReader("{\"a\": 1}\n[2]\n\n{\"b\": {}}\n"))
+	var docs []string
+	for {
+		doc, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		docs = append(docs, doc.encodeValue(doc.toplevel))
+	}
+	assert.Equal(t, []string{`{"a":1}`, `[2]`, `{"b":{}}`}, docs)
+
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.jsonl"), filepath.Join(dir, "b.json")
+	assert.Nil(t, os.WriteFile(a, []byte("{\"a\": 1}\n[2]\n"), 0644))
+	assert.Nil(t, os.WriteFile(b, []byte("{\"b\": [}"), 0644))
+	count := 0
+	add := func(*decodeResult) { count++ }
+	assert.Nil(t, ReadFiles([]string{a, a}, add))
+	assert.Equal(t, 4, count)
+	err := ReadFiles([]string{a, b}, add)
+	assert.True(t, strings.HasPrefix(err.Error(), b+": "), err.Error())
+	assert.NotNil(t, ReadFiles([]string{filepath.Join(dir, "missing.json")}, add))
+}
+
+func TestSchemaInferrer(t *testing.T) {
+	inferrer := NewSchemaInferrer()
+	for _, s := range []string{
+		`{"id": 1, "status": "open", "tags": ["x"], "score": 0.5}`,
+		`{"id": 2, "status": "closed", "tags": [], "owner": null}`,
+		`{"id": 3, "status": "open", "tags": ["y", "z"], "owner": {"name": "n"}}`,
+	} {
+		inferrer.Add(mustDecode(t, s))
+	}
+	schema := inferrer.Schema()
+	var buf bytes.Buffer
+	assert.Nil(t, schema.Encode(&buf, 0))
+	assert.Equal(t, `{"$schema":"https://json-schema.org/draft/2020-12/schema",`+
+		`"$comment":"inferred from 3 documents: objects = 4, arrays = 3, primitives = 12",`+
+		`"type":"object","properties":{`+
+		`"id":{"type":"integer","minimum":1,"maximum":3,"x-count":3},`+
+		`"status":{"type":"string","enum":["closed","open"],"x-count":3},`+
+		`"tags":{"type":"array","items":{"type":"string","x-count":3},"minItems":0,"maxItems":2,"x-count":3},`+
+		`"score":{"type":"number","minimum":0.5,"maximum":0.5,"x-count":1},`+
+		`"owner":{"type":["object","null"],"properties":{"name":{"type":"string","x-count":1}},"required":["name"],"x-count":2}},`+
+		`"required":["id","status","tags"],"x-count":3}`+"\n", buf.String())
+
+	// The inferred schema validates documents of the same shape.
+	buf.Reset()
+	assert.Nil(t, schema.Encode(&buf, 2))
+	s, err := NewSchema(&buf)
+	assert.Nil(t, err)
+	assert.Len(t, s.Validate(mustDecode(t, `{"id": 2, "status": "open", "tags": []}`)), 0)
+	assert.Len(t, s.Validate(mustDecode(t, `{"id": 2, "status": "new", "tags": []}`)), 1)
+}
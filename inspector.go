@@ -1,10 +1,16 @@
 package jsontools
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"os/exec"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/fatih/color"
 	"github.com/peterh/liner"
@@ -15,37 +21,156 @@ type Path string
 type stackItem struct {
 	value jsonValue
 	path  string
+	// segment is the member name or array index leading to the value.
+	segment string
 }
 
-type Inspector struct {
+// document holds the state of one of the documents open in an Inspector.
+type document struct {
+	// name is shown by the doc command. File is shown if it is empty.
+	name  string
 	json  *decodeResult
 	stack []*stackItem
+	// lazy is set when containers are decoded on demand.
+	lazy *lazyDocument
+	// results holds the matches of the last find or grep.
+	results []*searchResult
+	// File is where save writes the document by default.
+	File string
+	// saved is the root as last loaded or saved, which diff compares
+	// against.
+	saved                jsonValue
+	undoRoots, redoRoots []jsonValue
+	// previous holds the segments leading to the location before the last
+	// move, for "cd -".
+	previous []string
+	// mapped is the mapping the document was decoded from, if any, which
+	// Close releases.
+	mapped *MappedFile
 }
 
-func NewInspector(r io.Reader) (*Inspector, error) {
-	json, err := Decode(r)
-	if err != nil {
-		return nil, err
-	}
+func newDocument(json *decodeResult) *document {
 	stack := []*stackItem{
 		{
 			value: json.toplevel,
 			path:  "",
 		},
 	}
-	return &Inspector{
+	return &document{
 		json:  json,
 		stack: stack,
-	}, nil
+		saved: json.toplevel,
+	}
 }
 
-func (i *Inspector) findMember(obj *objectValue, name string) jsonValue {
-	for symid, v := range obj.props {
-		propName := i.json.symtab[symid]
-		if propName == name {
-			return v
+// modified reports whether the document has changed since it was loaded
+// or saved.
+func (d *document) modified() bool {
+	return d.stack[0].value != d.saved
+}
+
+// Inspector explores one or more documents. Commands apply to the current
+// one, which the doc command switches between.
+type Inspector struct {
+	*document
+	docs []*document
+	// HistoryFile is where Repl loads and saves the command history. No
+	// history is kept if it is empty.
+	HistoryFile string
+	// out receives the output of commands.
+	out io.Writer
+	// width is the width of the terminal, to which long values are cut,
+	// or zero if the output doesn't go to a terminal.
+	width int
+}
+
+func NewInspector(r io.Reader) (*Inspector, error) {
+	json, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return newInspector(json), nil
+}
+
+// NewLazyInspector returns an Inspector which only decodes the parts of
+// the document the user visits. It is meant for documents too large to
+// decode in full.
+func NewLazyInspector(r io.ReaderAt, size int64) (*Inspector, error) {
+	doc, err := newLazyDocument(r, size)
+	if err != nil {
+		return nil, err
+	}
+	i := newInspector(doc.decodeResult)
+	i.lazy = doc
+	return i, nil
+}
+
+// Close releases the mappings the documents of i were decoded from. The
+// Inspector must not be used afterwards.
+func (i *Inspector) Close() error {
+	var err error
+	for _, d := range i.docs {
+		if d.mapped == nil {
+			continue
+		}
+		if e := d.mapped.Close(); err == nil {
+			err = e
 		}
 	}
+	return err
+}
+
+// NewRecordsInspector returns an Inspector with a document for each
+// record of the JSON Lines in r, named after name and the line the record
+// starts on.
+func NewRecordsInspector(r io.Reader, name string) (*Inspector, error) {
+	i := &Inspector{out: os.Stdout}
+	reader := NewDocumentReader(r)
+	for {
+		json, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%s", name, err)
+		}
+		d := newDocument(json)
+		d.name = fmt.Sprintf("%s:%d", name, reader.Pos().Line)
+		i.docs = append(i.docs, d)
+	}
+	if len(i.docs) == 0 {
+		return nil, fmt.Errorf("%s: no records", name)
+	}
+	i.document = i.docs[0]
+	return i, nil
+}
+
+func newInspector(json *decodeResult) *Inspector {
+	d := newDocument(json)
+	return &Inspector{
+		document: d,
+		docs:     []*document{d},
+		out:      os.Stdout,
+	}
+}
+
+// Append adds the documents of other to the ones i holds.
+func (i *Inspector) Append(other *Inspector) {
+	i.docs = append(i.docs, other.docs...)
+}
+
+// resolve returns v, decoding it first if it hasn't been decoded yet.
+func (i *Inspector) resolve(v jsonValue) (jsonValue, error) {
+	if i.lazy == nil {
+		return v, nil
+	}
+	return i.lazy.resolve(v)
+}
+
+func (i *Inspector) findMember(obj *objectValue, name string) jsonValue {
+	if id, ok := i.memberId(obj, name); ok {
+		return obj.props[id]
+	}
 	return nil
 }
 
@@ -66,179 +191,720 @@ func (i *Inspector) current() *stackItem {
 	return i.stack[len(i.stack)-1]
 }
 
-func (i *Inspector) pushMember(name string, value jsonValue) {
-	path := i.current().path + "." + name
-	i.stack = append(i.stack, &stackItem{
-		value: value,
-		path:  path,
-	})
+func memberItem(parent *stackItem, name string, value jsonValue) *stackItem {
+	return &stackItem{
+		value:   value,
+		path:    parent.path + "." + name,
+		segment: name,
+	}
 }
 
-func (i *Inspector) pushValue(index int, value jsonValue) {
-	name := fmt.Sprintf("[%d]", index)
-	path := i.current().path + name
-	i.stack = append(i.stack, &stackItem{
-		value: value,
-		path:  path,
-	})
+func indexItem(parent *stackItem, index int, value jsonValue) *stackItem {
+	return &stackItem{
+		value:   value,
+		path:    parent.path + fmt.Sprintf("[%d]", index),
+		segment: strconv.Itoa(index),
+	}
 }
 
-func (i *Inspector) pop() {
-	l := len(i.stack)
-	if l == 1 {
-		return
+// segments returns the member names and indices leading from the root to
+// the current value.
+func (i *Inspector) segments() []string {
+	var segments []string
+	for _, item := range i.stack[1:] {
+		segments = append(segments, item.segment)
 	}
-	i.stack = i.stack[:l-1]
+	return segments
 }
 
-func (i *Inspector) moveTo(path []string) {
-	if len(path) == 0 {
-		return
+func displayLocation(item *stackItem) string {
+	if item.path == "" {
+		return "."
 	}
+	return item.path
+}
 
-	name := path[0]
-	rest := path[1:]
-	if name == "." {
-		i.moveTo(rest)
-		return
+// descend returns the stack reached by following path from the stack
+// from, and an error naming the segment where path can't be followed. The
+// stack returned with an error leads as far as path could be followed.
+func (i *Inspector) descend(from []*stackItem, path []string) ([]*stackItem, error) {
+	stack := append([]*stackItem(nil), from...)
+	for _, name := range path {
+		cur := stack[len(stack)-1]
+		switch name {
+		case ".":
+			continue
+		case "..":
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		switch v := cur.value.(type) {
+		case *objectValue:
+			value := i.findMember(v, name)
+			if value == nil {
+				return stack, fmt.Errorf("no member %q in %s", name, displayLocation(cur))
+			}
+			resolved, err := i.resolve(value)
+			if err != nil {
+				return stack, err
+			}
+			stack = append(stack, memberItem(cur, name, resolved))
+		case *arrayValue:
+			index, err := elemIndex(v, name, false)
+			if err != nil {
+				return stack, fmt.Errorf("%s in %s", err, displayLocation(cur))
+			}
+			resolved, err := i.resolve(v.elems[index])
+			if err != nil {
+				return stack, err
+			}
+			stack = append(stack, indexItem(cur, index, resolved))
+		default:
+			return stack, fmt.Errorf("%s is not an object or array", displayLocation(cur))
+		}
 	}
-	if name == ".." {
-		i.pop()
-		i.moveTo(rest)
-		return
+	return stack, nil
+}
+
+// navigate moves to the end of path, followed from the stack from. It
+// doesn't move at all if any segment of path fails.
+func (i *Inspector) navigate(from []*stackItem, path []string) error {
+	stack, err := i.descend(from, path)
+	if err != nil {
+		return err
+	}
+	i.previous = append([]string{}, i.segments()...)
+	i.stack = stack
+	return nil
+}
+
+// moveTo follows path from the current value.
+func (i *Inspector) moveTo(path []string) error {
+	return i.navigate(i.stack, path)
+}
+
+// cd moves to the path arg, or to the root if arg is empty, and back to
+// the previous location if arg is "-".
+func (i *Inspector) cd(arg string) error {
+	switch arg {
+	case "":
+		return i.navigate(i.stack[:1], nil)
+	case "-":
+		if i.previous == nil {
+			return errors.New("no previous location")
+		}
+		return i.navigate(i.stack[:1], i.previous)
+	}
+	path, absolute, err := parsePath(arg)
+	if err != nil {
+		return err
+	}
+	if absolute {
+		return i.navigate(i.stack[:1], path)
+	}
+	return i.moveTo(path)
+}
+
+// parsePath splits a path like "items/-1/name" into its segments. A
+// leading '/' makes the path absolute. Double quotes, as in
+// `"a/b c"/d`, keep '/' from separating segments, and escape sequences
+// within them are kept as they are, like member names. Negative indices
+// count from the end of an array.
+func parsePath(s string) ([]string, bool, error) {
+	absolute := strings.HasPrefix(s, "/")
+	var segments []string
+	var segment strings.Builder
+	quoted, hasQuotes := false, false
+	end := func() {
+		if segment.Len() > 0 || hasQuotes {
+			segments = append(segments, segment.String())
+		}
+		segment.Reset()
+		hasQuotes = false
+	}
+	for n := 0; n < len(s); n++ {
+		c := s[n]
+		switch {
+		case c == '"':
+			quoted = !quoted
+			hasQuotes = true
+		case c == '\\' && quoted && n+1 < len(s):
+			segment.WriteByte(c)
+			n++
+			segment.WriteByte(s[n])
+		case c == '/' && !quoted:
+			end()
+		default:
+			segment.WriteByte(c)
+		}
+	}
+	if quoted {
+		return nil, false, fmt.Errorf("unterminated \" in %s", s)
+	}
+	end()
+	return segments, absolute, nil
+}
+
+// quoteSegment quotes name for parsePath if it needs to be.
+func quoteSegment(name string) string {
+	if name == "" || name == "." || name == ".." || name == "-" || strings.ContainsAny(name, "/\" \t") {
+		return `"` + name + `"`
+	}
+	return name
+}
+
+// pwd returns the absolute path of the current value in the form cd
+// accepts.
+func (i *Inspector) pwd() string {
+	var buf strings.Builder
+	for _, segment := range i.segments() {
+		buf.WriteString("/")
+		buf.WriteString(quoteSegment(segment))
+	}
+	if buf.Len() == 0 {
+		return "/"
+	}
+	return buf.String()
+}
+
+// child returns the member or element of v called name, or nil if there
+// is none or it can't be decoded.
+func (i *Inspector) child(v jsonValue, name string) jsonValue {
+	var value jsonValue
+	switch cur := v.(type) {
+	case *objectValue:
+		value = i.findMember(cur, name)
+	case *arrayValue:
+		if index, err := elemIndex(cur, name, false); err == nil {
+			value = cur.elems[index]
+		}
+	}
+	if value == nil {
+		return nil
+	}
+	resolved, err := i.resolve(value)
+	if err != nil {
+		return nil
+	}
+	return resolved
+}
+
+var inspectorCommands = []string{
+	"cd", "ls", "pwd", "show", "tree", "summary", "table", "stats", "du", "doc", "compare", "find", "grep", "goto",
+	"set", "rm", "mv", "cp", "append", "rename", "undo", "redo", "diff", "save",
+}
+
+// pathCommands are the commands whose first argument is a path.
+var pathCommands = map[string]bool{
+	"cd":      true,
+	"compare": true,
+	"set":     true,
+	"rm":      true,
+	"mv":      true,
+	"cp":      true,
+	"append":  true,
+	"rename":  true,
+}
+
+// Values aren't cut to less than this many characters, however far to the
+// right they start.
+const minClipWidth = 10
+
+// Completing paths into huge arrays is cut short after this many
+// candidates.
+const maxCompletions = 1000
+
+// complete returns the candidates for completing line, which is either a
+// partial command or a command followed by a partial path.
+func (i *Inspector) complete(line string) []string {
+	var candidates []string
+	space := strings.IndexByte(line, ' ')
+	if space < 0 {
+		for _, cmd := range inspectorCommands {
+			if strings.HasPrefix(cmd, line) {
+				candidates = append(candidates, cmd+" ")
+			}
+		}
+		return candidates
+	}
+	if !pathCommands[line[:space]] {
+		return nil
+	}
+	head := line[:space+1]
+	arg := strings.TrimLeft(line[space+1:], " ")
+	head += line[space+1 : len(line)-len(arg)]
+
+	// Walk to the container named by everything up to the last '/'.
+	var stack []jsonValue
+	for _, item := range i.stack {
+		stack = append(stack, item.value)
+	}
+	if strings.HasPrefix(arg, "/") {
+		stack = stack[:1]
+	}
+	dir, prefix := "", arg
+	if slash := strings.LastIndexByte(arg, '/'); slash >= 0 {
+		dir, prefix = arg[:slash+1], arg[slash+1:]
+		for _, name := range strings.Split(arg[:slash], "/") {
+			switch name {
+			case "", ".":
+			case "..":
+				if len(stack) > 1 {
+					stack = stack[:len(stack)-1]
+				}
+			default:
+				v := i.child(stack[len(stack)-1], name)
+				if v == nil {
+					return nil
+				}
+				stack = append(stack, v)
+			}
+		}
 	}
 
-	switch cur := i.current().value.(type) {
+	add := func(name string, v jsonValue) bool {
+		if !strings.HasPrefix(name, prefix) {
+			return true
+		}
+		candidate := head + dir + name
+		switch v.(type) {
+		case *objectValue, *arrayValue, *lazyValue:
+			candidate += "/"
+		}
+		candidates = append(candidates, candidate)
+		return len(candidates) < maxCompletions
+	}
+	switch cur := stack[len(stack)-1].(type) {
 	case *objectValue:
-		if value := i.findMember(cur, name); value != nil {
-			i.pushMember(name, value)
+		for _, id := range cur.keys {
+			if !add(i.idToStr(id), cur.props[id]) {
+				break
+			}
 		}
 	case *arrayValue:
-		index, err := strconv.Atoi(name)
-		if err != nil || index < 0 || index >= len(cur.elems) {
-			return
+		for index, e := range cur.elems {
+			if !add(strconv.Itoa(index), e) {
+				break
+			}
 		}
-		i.pushValue(index, cur.elems[index])
 	}
-	i.moveTo(rest)
+	return candidates
 }
 
-func (i *Inspector) list(v jsonValue) {
-	switch value := v.(type) {
+// clip cuts s to fit on the terminal line after column, if the output
+// goes to a terminal.
+func (i *Inspector) clip(s string, column int) string {
+	if i.width == 0 {
+		return s
+	}
+	width := i.width - column
+	if width < minClipWidth {
+		width = minClipWidth
+	}
+	return truncateText(s, width)
+}
+
+// listCommand lists the members or elements of the current value, one per
+// line. "ls -n 10 --offset 100" lists ten of them, from the 100th on.
+func (i *Inspector) listCommand(args string) error {
+	fs := newFlagSet("ls")
+	count := fs.Int("n", 0, "number of entries to list, or 0 for all")
+	offset := fs.Int("offset", 0, "number of entries to skip")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	var size int
+	var entry func(n int) (string, jsonValue)
+	switch value := i.current().value.(type) {
 	case *objectValue:
-		for k, v := range value.props {
-			fmt.Printf("%s: %s\n", i.idToStr(k), i.valueToString(v))
+		size = len(value.keys)
+		entry = func(n int) (string, jsonValue) {
+			id := value.keys[n]
+			return i.idToStr(id), value.props[id]
 		}
 	case *arrayValue:
-		for index, v := range value.elems {
-			fmt.Printf("%d: %s\n", index, i.valueToString(v))
+		size = len(value.elems)
+		entry = func(n int) (string, jsonValue) {
+			return strconv.Itoa(n), value.elems[n]
 		}
+	default:
+		return fmt.Errorf("%s is not an object or array", displayLocation(i.current()))
 	}
+	start, end := *offset, size
+	if start < 0 || start > size {
+		return fmt.Errorf("offset %d is out of range", start)
+	}
+	if *count > 0 && start+*count < end {
+		end = start + *count
+	}
+	for n := start; n < end; n++ {
+		name, v := entry(n)
+		fmt.Fprintf(i.out, "%s: %s\n", name, i.clip(i.valueToString(v), utf8.RuneCountInString(name)+2))
+	}
+	if start > 0 || end < size {
+		metaColor.Fprintf(i.out, "-- %d-%d of %d --\n", start+1, end, size)
+	}
+	return nil
 }
 
 var literalColor = color.New(color.FgCyan)
 var valueColor = color.New(color.FgBlue)
 var memberColor = color.New(color.FgMagenta)
 
-func (i *Inspector) printValue(
-	v jsonValue, depth int, rows int, indent string) {
+// printValue prints v expanded depth levels deep, with at most width
+// members or elements of each container. column is where v starts on the
+// line.
+func (i *Inspector) printValue(v jsonValue, depth int, width int, indent string, column int) error {
+	if depth > 0 {
+		resolved, err := i.resolve(v)
+		if err != nil {
+			return err
+		}
+		v = resolved
+	}
+	// more prints the line standing for the children left out.
+	more := func(innerIndent string, count int) {
+		fmt.Fprintf(i.out, ",\n%s", innerIndent)
+		metaColor.Fprintf(i.out, "... %d more", count)
+	}
 	switch value := v.(type) {
 	case *literalValue:
-		literalColor.Printf("%s", i.valueToString(value))
-	case *stringValue, *numberValue:
-		valueColor.Printf("%s", i.valueToString(value))
+		literalColor.Fprintf(i.out, "%s", i.valueToString(value))
+	case *stringValue:
+		valueColor.Fprintf(i.out, "%s", i.clip(i.valueToString(value), column))
+	case *numberValue:
+		valueColor.Fprintf(i.out, "%s", i.valueToString(value))
 	case *objectValue:
-		if depth <= 0 || rows <= 0 {
-			fmt.Printf("[Object]")
+		if depth <= 0 {
+			fmt.Fprintf(i.out, "[Object]")
+		} else if len(value.keys) == 0 {
+			fmt.Fprintf(i.out, "{}")
 		} else {
-			fmt.Printf("{")
+			fmt.Fprintf(i.out, "{")
 			innerIndent := indent + "  "
-			count := rows
-			prefix := "\n"
-			for k, m := range value.props {
-				fmt.Printf("%s%s", prefix, innerIndent)
-				memberColor.Printf("%s", i.idToStr(k))
-				fmt.Printf(": ")
-				i.printValue(m, depth-1, rows/2, innerIndent)
-				count -= 1
-				if count <= 0 {
-					fmt.Printf("%s%s...", prefix, innerIndent)
+			for n, k := range value.keys {
+				if n == width {
+					more(innerIndent, len(value.keys)-n)
 					break
 				}
-				prefix = ",\n"
+				if n > 0 {
+					fmt.Fprintf(i.out, ",")
+				}
+				fmt.Fprintf(i.out, "\n%s", innerIndent)
+				memberColor.Fprintf(i.out, "%s", i.idToStr(k))
+				fmt.Fprintf(i.out, ": ")
+				column := len(innerIndent) + utf8.RuneCountInString(i.idToStr(k)) + 2
+				if err := i.printValue(value.props[k], depth-1, width, innerIndent, column); err != nil {
+					return err
+				}
 			}
-			fmt.Printf("\n%s}", indent)
+			fmt.Fprintf(i.out, "\n%s}", indent)
 		}
 	case *arrayValue:
-		if depth <= 0 || rows <= 0 {
-			fmt.Printf("[Array]")
+		if depth <= 0 {
+			fmt.Fprintf(i.out, "[Array]")
+		} else if len(value.elems) == 0 {
+			fmt.Fprintf(i.out, "[]")
 		} else {
-			fmt.Printf("[")
+			fmt.Fprintf(i.out, "[")
 			innerIndent := indent + "  "
-			prefix := "\n"
-			for count, e := range value.elems {
-				fmt.Printf("%s%s", prefix, innerIndent)
-				i.printValue(e, depth-1, rows/2, innerIndent)
-				if rows-count <= 0 {
-					fmt.Printf("%s%s...", prefix, innerIndent)
+			for n, e := range value.elems {
+				if n == width {
+					more(innerIndent, len(value.elems)-n)
 					break
 				}
-				prefix = ",\n"
+				if n > 0 {
+					fmt.Fprintf(i.out, ",")
+				}
+				fmt.Fprintf(i.out, "\n%s", innerIndent)
+				if err := i.printValue(e, depth-1, width, innerIndent, len(innerIndent)); err != nil {
+					return err
+				}
 			}
-			fmt.Printf("\n%s]", indent)
+			fmt.Fprintf(i.out, "\n%s]", indent)
 		}
 	default:
-		fmt.Printf("%s", i.valueToString(value))
+		fmt.Fprintf(i.out, "%s", i.valueToString(value))
 	}
-}
-
-func (i *Inspector) show(v jsonValue) {
-	i.printValue(v, 4, 32, "")
-	fmt.Println()
+	return nil
 }
 
 var metaColor = color.New(color.FgGreen)
 
 func (i *Inspector) showMetadata() {
 	if len(i.stack) == 1 {
-		fmt.Printf("# objects = %d, arrays = %d, primitives = %d\n",
+		fmt.Fprintf(i.out, "# objects = %d, arrays = %d, primitives = %d\n",
 			i.json.numObjects, i.json.numArrays, i.json.numPrimitives)
 	}
 	switch value := i.current().value.(type) {
 	case *objectValue:
-		metaColor.Printf("[Object] size = %d\n", len(value.props))
+		metaColor.Fprintf(i.out, "[Object] size = %d\n", len(value.props))
 	case *arrayValue:
-		metaColor.Printf("[Array] size = %d\n", len(value.elems))
+		metaColor.Fprintf(i.out, "[Array] size = %d\n", len(value.elems))
 	default:
-		metaColor.Printf("%s\n", i.valueToString(value))
+		metaColor.Fprintf(i.out, "%s\n", i.valueToString(value))
 	}
 }
 
+// collectSymbols lets lazy documents drop the symbols of values which the
+// inspector no longer holds.
+func (i *Inspector) collectSymbols() {
+	for _, d := range i.docs {
+		if d.lazy == nil {
+			continue
+		}
+		roots := []jsonValue{d.saved}
+		roots = append(roots, d.undoRoots...)
+		roots = append(roots, d.redoRoots...)
+		for _, item := range d.stack {
+			roots = append(roots, item.value)
+		}
+		d.lazy.collect(roots...)
+	}
+}
+
+// doCommand executes a single command, returning an error if it fails.
 func (i *Inspector) doCommand(line string) error {
-	if strings.HasPrefix(line, "ls") {
-		i.list(i.current().value)
-	} else if strings.HasPrefix(line, "cd") {
-		pathStr := strings.TrimSpace(line[2:])
-		path := strings.Split(pathStr, "/")
-		i.moveTo(path)
-	} else if line == "show" {
-		i.show(i.current().value)
-	} else if len(line) == 0 {
+	// Between commands, everything in use is reachable from the documents.
+	i.collectSymbols()
+	name, args, _ := strings.Cut(line, " ")
+	args = strings.TrimSpace(args)
+	switch name {
+	case "":
 		// No-op
-	} else {
-		fmt.Printf("Unrecognized: %s\n", line)
+	case "ls":
+		return i.listCommand(args)
+	case "cd":
+		return i.cd(args)
+	case "pwd":
+		fmt.Fprintln(i.out, i.pwd())
+	case "show":
+		return i.showCommand(args)
+	case "tree":
+		return i.treeCommand(args)
+	case "summary":
+		return i.summaryCommand(args)
+	case "table":
+		return i.tableCommand(args)
+	case "stats", "du":
+		return i.statsCommand(args)
+	case "doc":
+		return i.docCommand(args)
+	case "compare":
+		return i.compareCommand(args)
+	case "find":
+		return i.search(args, true)
+	case "grep":
+		return i.search(args, false)
+	case "goto":
+		return i.gotoResult(args)
+	default:
+		if command := editCommands[name]; command != nil {
+			return command(i, args)
+		}
+		return fmt.Errorf("unrecognized command: %s", line)
 	}
 	return nil
 }
 
+// splitCommands splits line at the semicolons which aren't inside a JSON
+// string, dropping empty commands.
+func splitCommands(line string) []string {
+	var commands []string
+	start := 0
+	quoted, escaped := false, false
+	add := func(end int) {
+		if command := strings.TrimSpace(line[start:end]); command != "" {
+			commands = append(commands, command)
+		}
+		start = end + 1
+	}
+	for n := 0; n < len(line); n++ {
+		switch c := line[n]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			add(n)
+		}
+	}
+	add(len(line))
+	return commands
+}
+
+// Lines of a script may be this long, to leave room for large JSON values.
+const maxScriptLineLength = 64 << 20
+
+// RunScript executes the commands in r, one per line or separated by
+// semicolons, stopping at the first one which fails. Blank lines and lines
+// starting with '#' are skipped.
+func (i *Inspector) RunScript(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxScriptLineLength)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, command := range splitCommands(line) {
+			if err := i.doCommand(command); err != nil {
+				return fmt.Errorf("line %d: %s: %s", n, command, err)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// pager writes the output of a command to stdout as it is produced. Once
+// it no longer fits on the terminal, the rest goes through $PAGER, or
+// else is shown a screen at a time.
+type pager struct {
+	line   *liner.State
+	height int
+	// buf holds the output until it is known not to fit, and lines
+	// counts the lines in it.
+	buf   bytes.Buffer
+	lines int
+	// paging is set once the output didn't fit.
+	paging bool
+	// cmd is the running $PAGER, and stdin its input.
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// partial is the unfinished last line, and shown the number of lines
+	// on the screen, when paging without $PAGER.
+	partial []byte
+	shown   int
+	// quit is set when the rest of the output is to be dropped.
+	quit bool
+}
+
+func (p *pager) Write(b []byte) (int, error) {
+	switch {
+	case p.quit:
+	case p.stdin != nil:
+		if _, err := p.stdin.Write(b); err != nil {
+			// The pager has quit.
+			p.quit = true
+		}
+	case p.paging:
+		p.show(b)
+	default:
+		p.buf.Write(b)
+		p.lines += bytes.Count(b, []byte("\n"))
+		if p.lines >= p.height {
+			p.paging = true
+			p.start()
+		}
+	}
+	return len(b), nil
+}
+
+// start sends the output so far to $PAGER, or starts showing it a screen
+// at a time.
+func (p *pager) start() {
+	out := p.buf.Bytes()
+	if pager := os.Getenv("PAGER"); pager != "" {
+		cmd := exec.Command("sh", "-c", pager)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if os.Getenv("LESS") == "" {
+			// Like git, have less show colors and quit if the output
+			// fits after all.
+			cmd.Env = append(os.Environ(), "LESS=FRX")
+		}
+		stdin, err := cmd.StdinPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err == nil {
+			p.cmd, p.stdin = cmd, stdin
+			p.Write(out)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Pager failed: %s\n", err)
+	}
+	p.show(out)
+}
+
+// show writes the complete lines of b, asking before each new screen.
+func (p *pager) show(b []byte) {
+	pageSize := p.height - 1
+	if pageSize < 1 {
+		pageSize = 1
+	}
+	for !p.quit {
+		n := bytes.IndexByte(b, '\n')
+		if n < 0 {
+			p.partial = append(p.partial, b...)
+			return
+		}
+		if p.shown == pageSize {
+			answer, err := p.line.Prompt("-- Enter for more, q to quit -- ")
+			if err != nil || strings.TrimSpace(answer) == "q" {
+				p.quit = true
+				return
+			}
+			p.shown = 0
+		}
+		os.Stdout.Write(p.partial)
+		os.Stdout.Write(b[:n+1])
+		p.partial = p.partial[:0]
+		p.shown++
+		b = b[n+1:]
+	}
+}
+
+// Close writes the rest of the output and waits for $PAGER to finish.
+func (p *pager) Close() {
+	switch {
+	case p.stdin != nil:
+		p.stdin.Close()
+		if err := p.cmd.Wait(); err != nil {
+			fmt.Fprintf(os.Stderr, "Pager failed: %s\n", err)
+		}
+	case !p.paging:
+		os.Stdout.Write(p.buf.Bytes())
+	case len(p.partial) > 0:
+		p.show([]byte("\n"))
+	}
+}
+
+func (i *Inspector) saveHistory(line *liner.State) {
+	f, err := os.Create(i.HistoryFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot save history: %s\n", err)
+		return
+	}
+	defer f.Close()
+	line.WriteHistory(f)
+}
+
 func (i *Inspector) Repl() error {
 	line := liner.NewLiner()
 	defer line.Close()
 
 	line.SetCtrlCAborts(true)
+	line.SetCompleter(i.complete)
+	if i.HistoryFile != "" {
+		if f, err := os.Open(i.HistoryFile); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+		defer i.saveHistory(line)
+	}
 	for {
+		width, height, terminal := terminalSize()
 		i.showMetadata()
-		l, err := line.Prompt(i.current().path + "> ")
+		prompt := i.current().path + "> "
+		if len(i.docs) > 1 {
+			prompt = fmt.Sprintf("[%d] %s", i.docIndex()+1, prompt)
+		}
+		l, err := line.Prompt(prompt)
 		if err != nil {
 			// Map SIGINT to EOF
 			if err == liner.ErrPromptAborted {
@@ -246,8 +912,22 @@ func (i *Inspector) Repl() error {
 			}
 			return err
 		}
-		if err := i.doCommand(l); err != nil {
-			return err
+		if strings.TrimSpace(l) != "" {
+			line.AppendHistory(l)
+		}
+		if !terminal {
+			if err := i.doCommand(strings.TrimSpace(l)); err != nil {
+				fmt.Fprintf(i.out, "Error: %s\n", err)
+			}
+			continue
+		}
+		out := &pager{line: line, height: height}
+		i.out, i.width = out, width
+		err = i.doCommand(strings.TrimSpace(l))
+		i.out, i.width = os.Stdout, 0
+		out.Close()
+		if err != nil {
+			fmt.Fprintf(i.out, "Error: %s\n", err)
 		}
 	}
 	return nil
@@ -0,0 +1,433 @@
+package jsontools
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustInspector(t *testing.T, s string) *Inspector {
+	i, err := NewInspector(strings.NewReader(s))
+	assert.Nil(t, err)
+	return i
+}
+
+func TestInspectorComplete(t *testing.T) {
+	i := mustInspector(t, `{"items": [{"sku": "a"}, 2], "id": 1, "info": {"x": []}}`)
+	assert.Equal(t, []string{"cd ", "ls "}, i.complete("")[:2])
+	assert.Equal(t, []string{"show "}, i.complete("sh"))
+	assert.Equal(t, []string{"cd items/", "cd id", "cd info/"}, i.complete("cd i"))
+	assert.Equal(t, []string{"cd  info/"}, i.complete("cd  inf"))
+	assert.Equal(t, []string{"cd items/0/", "cd items/1"}, i.complete("cd items/"))
+	assert.Equal(t, []string{"cd items/0/sku"}, i.complete("cd items/0/"))
+	assert.Equal(t, []string{"cd info/../info/x/"}, i.complete("cd info/../info/"))
+	assert.Nil(t, i.complete("cd nothing/"))
+	assert.Nil(t, i.complete("ls i"))
+
+	i.moveTo([]string{"items"})
+	assert.Equal(t, []string{"cd ../items/", "cd ../id", "cd ../info/"}, i.complete("cd ../i"))
+	assert.Equal(t, []string{"cd 0/", "cd 1"}, i.complete("cd "))
+}
+
+func resultPaths(i *Inspector) []string {
+	var paths []string
+	for _, r := range i.results {
+		paths = append(paths, r.path+" "+r.preview)
+	}
+	return paths
+}
+
+func TestInspectorSearch(t *testing.T) {
+	i := mustInspector(t, `{"order": {"id": 7, "items": [{"sku": "A-1", "qty": 2}, {"sku": "B-2", "note": "sku"}]}}`)
+	i.search("sku", true)
+	assert.Equal(t, []string{`.order.items[0].sku "A-1"`, `.order.items[1].sku "B-2"`}, resultPaths(i))
+	i.search("sku", false)
+	assert.Equal(t, []string{`.order.items[1].note "sku"`}, resultPaths(i))
+	i.search("value>1", false)
+	assert.Equal(t, []string{`.order.id 7.000000`, `.order.items[0].qty 2.000000`}, resultPaths(i))
+	i.search("/^(items|id)$/", true)
+	assert.Equal(t, []string{`.order.id 7.000000`, `.order.items [Array]`}, resultPaths(i))
+
+	i.gotoResult("2")
+	assert.Equal(t, ".order.items", i.current().path)
+	i.search(`"B-2"`, false)
+	assert.Equal(t, []string{`.order.items[1].sku "B-2"`}, resultPaths(i))
+	i.moveTo([]string{"0"})
+	i.gotoResult("1")
+	assert.Equal(t, ".order.items[1].sku", i.current().path)
+	i.gotoResult("2")
+	assert.Equal(t, ".order.items[1].sku", i.current().path)
+
+	i.search("(", true)
+	assert.Equal(t, 1, len(i.results))
+}
+
+func TestInspectorSearchNumbers(t *testing.T) {
+	src := `{"price": 1.50, "count": 1e3, "id": 7}`
+	i := mustInspector(t, src)
+	index, err := NewIndexer(strings.NewReader(src)).CreateIndex()
+	assert.Nil(t, err)
+	for _, q := range []string{`1.50`, `1e3`, `7`, `1.5`, `1000`} {
+		i.search(q, false)
+		result, err := index.Search(q, &SearchOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, len(result.Entries), len(i.results), q)
+	}
+	i.search(`1.50`, false)
+	assert.Equal(t, []string{`.price 1.500000`}, resultPaths(i))
+}
+
+func TestInspectorEdit(t *testing.T) {
+	i := mustInspector(t, `{"name": "app", "servers": [{"host": "a"}, {"host": "b"}], "debug": true}`)
+	document := func() string {
+		return i.json.encodeValue(i.root())
+	}
+	for _, c := range []struct {
+		command  string
+		expected string
+	}{
+		{`set name "api"`, `{"name":"api","servers":[{"host":"a"},{"host":"b"}],"debug":true}`},
+		{`set servers/1/port 80`, `{"name":"api","servers":[{"host":"a"},{"host":"b","port":80}],"debug":true}`},
+		{`set servers/2 {"host": "c"}`, `{"name":"api","servers":[{"host":"a"},{"host":"b","port":80},{"host":"c"}],"debug":true}`},
+		{`rm servers/0`, `{"name":"api","servers":[{"host":"b","port":80},{"host":"c"}],"debug":true}`},
+		{`rename debug verbose`, `{"name":"api","servers":[{"host":"b","port":80},{"host":"c"}],"verbose":true}`},
+		{`mv verbose servers/1/verbose`, `{"name":"api","servers":[{"host":"b","port":80},{"host":"c","verbose":true}]}`},
+		{`cp servers/0/port servers/1/port`, `{"name":"api","servers":[{"host":"b","port":80},{"host":"c","verbose":true,"port":80}]}`},
+		{`append servers null`, `{"name":"api","servers":[{"host":"b","port":80},{"host":"c","verbose":true,"port":80},null]}`},
+	} {
+		i.doCommand(c.command)
+		assert.Equal(t, c.expected, document(), c.command)
+	}
+
+	i.moveTo([]string{"servers", "1"})
+	i.doCommand("set host \"d\"")
+	i.doCommand("rm ../2")
+	assert.Equal(t, ".servers[1]", i.current().path)
+	assert.Equal(t, `{"name":"api","servers":[{"host":"b","port":80},{"host":"d","verbose":true,"port":80}]}`, document())
+
+	for _, command := range []string{
+		"set nothing/x 1", "set . {", "rm ../../..", "mv .. ../x", "rename host port", "append host 1", "set",
+	} {
+		before := document()
+		i.doCommand(command)
+		assert.Equal(t, before, document(), command)
+	}
+
+	for n := 0; n < 3; n++ {
+		i.doCommand("undo")
+	}
+	assert.Equal(t, `{"name":"api","servers":[{"host":"b","port":80},{"host":"c","verbose":true,"port":80}]}`, document())
+	i.doCommand("redo")
+	assert.Equal(t, `{"name":"api","servers":[{"host":"b","port":80},{"host":"c","verbose":true,"port":80},null]}`, document())
+	i.doCommand("set ../../name \"web\"")
+	assert.NotNil(t, i.redoCommand(""))
+
+	d, err := i.changes()
+	assert.Nil(t, err)
+	var paths []string
+	for _, e := range d.Entries {
+		paths = append(paths, e.Kind.String()+" "+e.Path())
+	}
+	assert.Equal(t, []string{
+		"changed .name", "changed .servers[0].host", "added .servers[0].port", "changed .servers[1].host",
+		"added .servers[1].verbose", "added .servers[1].port", "added .servers[2]", "removed .debug",
+	}, paths)
+	for n := 0; n < 11; n++ {
+		i.doCommand("undo")
+	}
+	assert.NotNil(t, i.undoCommand(""))
+	d, err = i.changes()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(d.Entries))
+}
+
+func TestInspectorSave(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	assert.Nil(t, os.WriteFile(name, []byte("{\n\t\"b\": 1,\n\t\"a\": [1, 2]\n}\n"), 0600))
+	f, err := os.Open(name)
+	assert.Nil(t, err)
+	defer f.Close()
+	i, err := NewInspector(f)
+	assert.Nil(t, err)
+	i.File = name
+
+	i.doCommand("set b 2")
+	assert.Nil(t, i.saveCommand(""))
+	data, err := os.ReadFile(name)
+	assert.Nil(t, err)
+	assert.Equal(t, "{\n\t\"b\": 2,\n\t\"a\": [\n\t\t1,\n\t\t2\n\t]\n}\n", string(data))
+	d, err := i.changes()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(d.Entries))
+
+	src := `{"id": 12345678901234567890, "price": 1.50, "n": 1e3, "x": 0}`
+	assert.Nil(t, os.WriteFile(name, []byte(src), 0600))
+	i = mustInspector(t, src)
+	i.File = name
+	i.doCommand("set x 1")
+	assert.Nil(t, i.saveCommand(""))
+	data, err = os.ReadFile(name)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":12345678901234567890,"price":1.50,"n":1e3,"x":1}`+"\n", string(data))
+
+	assert.Equal(t, "", detectIndent([]byte(`{"a": {"b": 1}}`+"\n")))
+	assert.Equal(t, "    ", detectIndent([]byte("[\n{\n    \"a\": 1}]")))
+	assert.Equal(t, "  ", detectIndent([]byte("[\n1,\n2]")))
+}
+
+func TestRunScript(t *testing.T) {
+	assert.Equal(t, []string{"cd a", `set b "x;\"y;"`, "show"}, splitCommands(` cd a; set b "x;\"y;";; show;`))
+
+	i := mustInspector(t, `{"a": {"b": [1, 2]}}`)
+	assert.Nil(t, i.RunScript(strings.NewReader("# setup\ncd a/b\n\nappend . 3; cd ..; set c true\n")))
+	assert.Equal(t, ".a", i.current().path)
+	assert.Equal(t, `{"a":{"b":[1,2,3],"c":true}}`, i.json.encodeValue(i.root()))
+
+	err := i.RunScript(strings.NewReader("cd b\nls; frobnicate; set x 1\nset y 1"))
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "line 2: frobnicate: unrecognized command: frobnicate", err.Error())
+	}
+	assert.Equal(t, `{"a":{"b":[1,2,3],"c":true}}`, i.json.encodeValue(i.root()))
+	err = i.RunScript(strings.NewReader(`find (`))
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "line 1: find (: query: column 2: expected a term", err.Error())
+	}
+}
+
+func TestInspectorNavigate(t *testing.T) {
+	i := mustInspector(t, `{"a": {"b c": [10, 20, {"x/y": 1}]}, "s": "str", "q\"k": 2}`)
+	assert.Nil(t, i.cd(`a/"b c"/-1/"x/y"`))
+	assert.Equal(t, `.a.b c[2].x/y`, i.current().path)
+	assert.Equal(t, `/a/"b c"/2/"x/y"`, i.pwd())
+
+	for arg, msg := range map[string]string{
+		`../../5`:         `no element 5 in .a.b c`,
+		`../../-4`:        `no element -4 in .a.b c`,
+		`../../first`:     `"first" is not an array index in .a.b c`,
+		`/a/nothing/x`:    `no member "nothing" in .a`,
+		`/s/x`:            `.s is not an object or array`,
+		`"unterminated/x`: `unterminated " in "unterminated/x`,
+	} {
+		err := i.cd(arg)
+		if assert.NotNil(t, err, arg) {
+			assert.Equal(t, msg, err.Error(), arg)
+		}
+		assert.Equal(t, `.a.b c[2].x/y`, i.current().path, arg)
+	}
+
+	assert.Nil(t, i.cd(`/a/"b c"/-3`))
+	assert.Equal(t, `.a.b c[0]`, i.current().path)
+	assert.Nil(t, i.cd(`-`))
+	assert.Equal(t, `.a.b c[2].x/y`, i.current().path)
+	assert.Nil(t, i.cd(`-`))
+	assert.Equal(t, `.a.b c[0]`, i.current().path)
+	assert.Nil(t, i.cd(``))
+	assert.Equal(t, "/", i.pwd())
+	assert.Nil(t, i.cd(`"q\"k"`))
+	assert.Equal(t, `/"q\"k"`, i.pwd())
+
+	i = mustInspector(t, `{"a": {"b": [1, 2]}}`)
+	assert.Nil(t, i.cd("a"))
+	assert.Nil(t, i.RunScript(strings.NewReader(`set "new key" [3]; append b/ -1; rename "new key" "x/y"; rm /a/b/0`)))
+	assert.Equal(t, `{"a":{"b":[2,-1],"x/y":[3]}}`, i.json.encodeValue(i.root()))
+	assert.Nil(t, i.cd("-"))
+	assert.Equal(t, "", i.current().path)
+	assert.NotNil(t, mustInspector(t, `[]`).cd("-"))
+}
+
+func TestInspectorSummary(t *testing.T) {
+	i := mustInspector(t, `[
+  {"id": 1, "status": "ok", "tags": []},
+  {"id": 2, "status": "ok"},
+  {"id": 3.5, "status": null, "extra": {"a": 1}},
+  "loose"
+]`)
+	var rows []string
+	fields, err := i.summarize(i.current().value.(*arrayValue))
+	assert.Nil(t, err)
+	for _, f := range fields {
+		rows = append(rows, fmt.Sprintf("%s|%d|%s|%s", f.name, f.count, f.typesString(), f.valuesString()))
+	}
+	assert.Equal(t, []string{
+		"id|3|number|3 distinct; 1 (1), 2 (1), 3.5 (1); range 1 to 3.5",
+		`status|3|string 2, null 1|2 distinct; "ok" (2), null (1)`,
+		"tags|1|array|",
+		"extra|1|object|",
+		`(element)|1|string|1 distinct; "loose" (1)`,
+	}, rows)
+
+	assert.Equal(t, "array[4]", typeLabel(i.current().value))
+	assert.Equal(t, "boolean", typeLabel(&literalValue{True}))
+	assert.NotNil(t, i.showCommand("-d x"))
+	assert.NotNil(t, i.treeCommand("extra"))
+	assert.Nil(t, i.cd("0"))
+	assert.NotNil(t, i.summaryCommand(""))
+}
+
+func tableRows(i *Inspector, t *tableView) []string {
+	var rows []string
+	for _, row := range t.rows {
+		s := strconv.Itoa(row.index)
+		for _, v := range row.cells {
+			s += "|" + i.cellText(v)
+		}
+		rows = append(rows, s)
+	}
+	return rows
+}
+
+func TestInspectorTable(t *testing.T) {
+	i := mustInspector(t, `[
+  {"name": "b \"two\"", "size": 10},
+  {"name": "a", "size": 9, "tags": [1]},
+  {"size": 100},
+  {"name": "c", "size": "n/a"}
+]`)
+	arr := i.current().value.(*arrayValue)
+	table, err := i.newTableView(arr, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"name", "size", "tags"}, table.columns)
+	assert.Equal(t, []string{`0|b \"two\"|10|`, "1|a|9|array[1]", "2||100|", "3|c|n/a|"}, tableRows(i, table))
+
+	assert.Nil(t, i.sortTable(table, "size", false))
+	assert.Equal(t, []string{"1|a|9|array[1]", `0|b \"two\"|10|`, "2||100|", "3|c|n/a|"}, tableRows(i, table))
+	assert.Nil(t, i.sortTable(table, "name", true))
+	assert.Equal(t, []string{"3|c|n/a|", `0|b \"two\"|10|`, "1|a|9|array[1]", "2||100|"}, tableRows(i, table))
+	assert.NotNil(t, i.sortTable(table, "color", false))
+
+	table, err = i.newTableView(arr, []string{"size", "name"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`0|10|b \"two\"`, "1|9|a", "2|100|", "3|n/a|c"}, tableRows(i, table))
+	_, err = i.newTableView(arr, []string{"color"})
+	assert.NotNil(t, err)
+
+	name := filepath.Join(t.TempDir(), "out.csv")
+	assert.Nil(t, i.tableCommand("-sort size -csv "+name+" name size"))
+	data, err := os.ReadFile(name)
+	assert.Nil(t, err)
+	assert.Equal(t, "name,size\na,9\n\"b \"\"two\"\"\",10\n,100\nc,n/a\n", string(data))
+	assert.NotNil(t, i.tableCommand("-offset 5"))
+
+	assert.Equal(t, "abcdef", truncateText("abcdef", 6))
+	assert.Equal(t, "ab...", truncateText("abcdef", 5))
+	assert.Equal(t, "éé...", truncateText("éééééé", 5))
+	assert.Equal(t, []string{"-sort", "full name", "", "x"}, splitFields(`-sort "full name"  "" x`))
+}
+
+// commandOutput runs command and returns what it printed.
+func commandOutput(t *testing.T, i *Inspector, command string) string {
+	var buf bytes.Buffer
+	i.out = &buf
+	defer func() { i.out = os.Stdout }()
+	assert.Nil(t, i.doCommand(command), command)
+	return buf.String()
+}
+
+func TestInspectorOutput(t *testing.T) {
+	i := mustInspector(t, `{"z": "a long string value", "y": [1, 2, 3, 4, 5], "x": {"w": "short"}}`)
+	assert.Equal(t, "z: a long string value\ny: [Array]\nx: [Object]\n", commandOutput(t, i, "ls"))
+	assert.Equal(t, "y: [Array]\n-- 2-2 of 3 --\n", commandOutput(t, i, "ls -n 1 --offset 1"))
+
+	i.width = 16
+	assert.Equal(t, "z: a long str...\ny: [Array]\nx: [Object]\n", commandOutput(t, i, "ls"))
+	assert.Equal(t, `{
+  z: a long s...,
+  y: [
+    1.000000,
+    2.000000,
+    ... 3 more
+  ],
+  ... 1 more
+}
+`, commandOutput(t, i, "show -d 2 -w 2"))
+	i.width = 0
+
+	assert.Nil(t, i.cd("y"))
+	assert.Equal(t, "3: 4.000000\n4: 5.000000\n-- 4-5 of 5 --\n", commandOutput(t, i, "ls -offset 3"))
+	assert.NotNil(t, i.doCommand("ls -offset 6"))
+	assert.Nil(t, i.cd("0"))
+	assert.NotNil(t, i.doCommand("ls"))
+}
+
+func TestInspectorStats(t *testing.T) {
+	src := `{"items": [{"id": 1, "name": "a \"b\""}, {"id": 2.5, "tags": [true, null]}], "note": "x", "empty": {}}`
+	i := mustInspector(t, src)
+	st := &treeStats{counts: make(map[string]int), keys: make(map[string]int)}
+	root := i.current().value
+	size, err := i.measure(root, 0, st)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(i.json.encodeValue(root))), size)
+	assert.Equal(t, map[string]int{"object": 4, "array": 2, "string": 2, "number": 2, "boolean": 1, "null": 1}, st.counts)
+	assert.Equal(t, 4, st.maxDepth)
+	assert.Equal(t, []string{"id", "empty", "items", "name", "note", "tags"}, byCount(st.keys))
+
+	assert.Equal(t, `.: 89 B, depth 4
+object 4, array 2, string 2, number 2, boolean 1, null 1
+     57 B  64.0%  items
+      3 B   3.4%  note
+... 1 more
+Most frequent members:
+  id: 2
+`, commandOutput(t, i, "du -n 2 -k 1"))
+	assert.Equal(t, "1.0 KB", formatSize(1024))
+	assert.Equal(t, "1.5 MB", formatSize(3<<19))
+}
+
+func TestInspectorDocuments(t *testing.T) {
+	i, err := NewRecordsInspector(strings.NewReader("{\"a\": 1, \"b\": [1]}\n\n{\"a\": 2, \"b\": [1], \"c\": true}\n"), "r.jsonl")
+	assert.Nil(t, err)
+	i.Append(mustInspector(t, `{"a": 1}`))
+	assert.Equal(t, 3, len(i.docs))
+
+	assert.Nil(t, i.cd("b"))
+	assert.Nil(t, i.doCommand("doc 3"))
+	assert.Equal(t, "/", i.pwd())
+	assert.Nil(t, i.doCommand("set /a 5"))
+	assert.Nil(t, i.doCommand("doc r.jsonl:1"))
+	assert.Equal(t, "/b", i.pwd())
+	assert.Equal(t, "* 1: r.jsonl:1\n  2: r.jsonl:3\n  3: document 3 (modified)\n", commandOutput(t, i, "doc"))
+	assert.NotNil(t, i.doCommand("doc 4"))
+	assert.NotNil(t, i.doCommand("doc r.jsonl:2"))
+
+	assert.Equal(t, `   r.jsonl:1  r.jsonl:3  document 3
+a  1          2          5
+b  array[1]   array[1]   -
+c  -          true       -
+`, commandOutput(t, i, "compare /"))
+	assert.Equal(t, "      r.jsonl:1  r.jsonl:3  document 3\n/b/0  1          1          -\n", commandOutput(t, i, "compare -d 0"))
+	assert.Equal(t, "/b", i.pwd())
+
+	_, err = NewRecordsInspector(strings.NewReader("\n"), "empty.jsonl")
+	assert.NotNil(t, err)
+	_, err = NewRecordsInspector(strings.NewReader("{}\n[\n"), "bad.jsonl")
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "bad.jsonl:3:"), err.Error())
+
+	long := `{"s": "` + strings.Repeat("x", 100000) + `"}`
+	i, err = NewRecordsInspector(strings.NewReader("[1]\n"+long+"\n\n[2]\n"), "long.jsonl")
+	assert.Nil(t, err)
+	assert.Equal(t, "* 1: long.jsonl:1\n  2: long.jsonl:2\n  3: long.jsonl:4\n", commandOutput(t, i, "doc"))
+}
+
+func TestPager(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "paged")
+	t.Setenv("PAGER", "cat > "+name)
+	p := &pager{height: 3}
+	for n := 0; n < 5; n++ {
+		fmt.Fprintf(p, "line %d\n", n)
+		if n == 1 {
+			assert.Nil(t, p.cmd)
+		}
+	}
+	assert.NotNil(t, p.cmd)
+	p.Close()
+	data, err := os.ReadFile(name)
+	assert.Nil(t, err)
+	assert.Equal(t, "line 0\nline 1\nline 2\nline 3\nline 4\n", string(data))
+}
@@ -0,0 +1,374 @@
+package jsontools
+
+import (
+	"bufio"
+	"container/list"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Containers smaller than this are decoded together with their parent
+// rather than being recorded by the pre-scan.
+const minLazySize = 64 * 1024
+
+// Number of decoded containers kept by a lazyDocument.
+const lazyCacheSize = 256
+
+// lazyEntry describes a container found by the pre-scan.
+type lazyEntry struct {
+	start int64
+	// end is the offset just past the closing bracket.
+	end int64
+	// kind is '{' or '['.
+	kind byte
+	// children are the largest recorded containers inside this one, in
+	// document order.
+	children []*lazyEntry
+}
+
+// lazyValue stands for a container which hasn't been decoded yet.
+type lazyValue struct {
+	entry *lazyEntry
+}
+
+func (v *lazyValue) ToString() string {
+	if v.entry.kind == '{' {
+		return "[Object]"
+	}
+	return "[Array]"
+}
+
+type prescanFrame struct {
+	start    int64
+	kind     byte
+	children []*lazyEntry
+}
+
+// prescanner finds the byte offsets of large containers without decoding
+// anything, and counts values along the way.
+type prescanner struct {
+	r      *bufio.Reader
+	offset int64
+	stack  []*prescanFrame
+	// prev is the last structural character seen outside a string.
+	prev          byte
+	numObjects    int64
+	numArrays     int64
+	numPrimitives int64
+}
+
+func (p *prescanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("offset %d: %s", p.offset, fmt.Sprintf(format, args...))
+}
+
+// skipString consumes the rest of a string whose opening quote has been
+// read.
+func (p *prescanner) skipString() error {
+	escaped := false
+	for {
+		b, err := p.r.ReadByte()
+		if err != nil {
+			return p.errorf("unterminated string")
+		}
+		p.offset++
+		switch {
+		case escaped:
+			escaped = false
+		case b == '\\':
+			escaped = true
+		case b == '"':
+			return nil
+		}
+	}
+}
+
+// startsValue reports whether a token following p.prev is a value rather
+// than a member name.
+func (p *prescanner) startsValue() bool {
+	switch p.prev {
+	case ':', '[':
+		return true
+	case ',':
+		top := p.stack[len(p.stack)-1]
+		return top.kind == '['
+	}
+	return false
+}
+
+func (p *prescanner) scan() (*lazyEntry, error) {
+	for {
+		b, err := p.r.ReadByte()
+		if err == io.EOF {
+			return nil, p.errorf("unexpected end of input")
+		}
+		if err != nil {
+			return nil, err
+		}
+		start := p.offset
+		p.offset++
+		switch b {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case '{', '[':
+			if len(p.stack) == 0 && p.prev != 0 {
+				return nil, p.errorf("unexpected '%c'", b)
+			}
+			if b == '{' {
+				p.numObjects++
+			} else {
+				p.numArrays++
+			}
+			p.stack = append(p.stack, &prescanFrame{start: start, kind: b})
+		case '}', ']':
+			if len(p.stack) == 0 {
+				return nil, p.errorf("unexpected '%c'", b)
+			}
+			frame := p.stack[len(p.stack)-1]
+			p.stack = p.stack[:len(p.stack)-1]
+			if (frame.kind == '{') != (b == '}') {
+				return nil, p.errorf("mismatched '%c'", b)
+			}
+			entry := &lazyEntry{
+				start:    frame.start,
+				end:      p.offset,
+				kind:     frame.kind,
+				children: frame.children,
+			}
+			if len(p.stack) == 0 {
+				return entry, nil
+			}
+			if entry.end-entry.start >= minLazySize {
+				parent := p.stack[len(p.stack)-1]
+				parent.children = append(parent.children, entry)
+			}
+		case '"':
+			if len(p.stack) == 0 {
+				return nil, p.errorf("expected object or array")
+			}
+			if p.startsValue() {
+				p.numPrimitives++
+			}
+			if err := p.skipString(); err != nil {
+				return nil, err
+			}
+		case ':', ',':
+		default:
+			if len(p.stack) == 0 {
+				return nil, p.errorf("expected object or array")
+			}
+			if p.startsValue() {
+				p.numPrimitives++
+			}
+		}
+		p.prev = b
+	}
+}
+
+// lazyDocument decodes the containers of a document as they are needed.
+type lazyDocument struct {
+	*decodeResult
+	r           io.ReaderAt
+	symtabMaker *symtabMaker
+	cache       map[*lazyEntry]*list.Element
+	lru         *list.List
+	// evictions counts the containers dropped from the cache since the
+	// symbol table was last collected.
+	evictions int
+}
+
+type lazyCacheItem struct {
+	entry *lazyEntry
+	value jsonValue
+}
+
+// newLazyDocument pre-scans the document in r and decodes its top level.
+func newLazyDocument(r io.ReaderAt, size int64) (*lazyDocument, error) {
+	p := &prescanner{
+		r: bufio.NewReaderSize(io.NewSectionReader(r, 0, size), 1<<20),
+	}
+	root, err := p.scan()
+	if err != nil {
+		return nil, err
+	}
+	m := newSymtabMaker()
+	d := &lazyDocument{
+		decodeResult: &decodeResult{
+			symtab:        m.symtab,
+			inverted:      m.inverted,
+			numObjects:    p.numObjects,
+			numArrays:     p.numArrays,
+			numPrimitives: p.numPrimitives,
+			maker:         m,
+		},
+		r:           r,
+		symtabMaker: m,
+		cache:       make(map[*lazyEntry]*list.Element),
+		lru:         list.New(),
+	}
+	d.resolveLazy = d.resolve
+	d.toplevel, err = d.load(root)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// lazyClient decodes a container whose large children have been replaced
+// by empty placeholders.
+type lazyClient struct {
+	*decoderClient
+	// placeholders maps the offsets of the placeholders to the children
+	// they stand for.
+	placeholders map[int]*lazyEntry
+}
+
+func (c *lazyClient) replacePlaceholder() {
+	if e, ok := c.placeholders[c.parser.TokenPos().Offset]; ok {
+		c.stack[len(c.stack)-1] = &lazyValue{e}
+	}
+}
+
+func (c *lazyClient) StartObject() {
+	c.decoderClient.StartObject()
+	c.replacePlaceholder()
+}
+
+func (c *lazyClient) StartArray() {
+	c.decoderClient.StartArray()
+	c.replacePlaceholder()
+}
+
+// decode reads e from the source, skipping over its large children.
+func (d *lazyDocument) decode(e *lazyEntry) (jsonValue, error) {
+	c := &lazyClient{
+		decoderClient: &decoderClient{
+			symtabMaker: d.symtabMaker,
+		},
+		placeholders: make(map[int]*lazyEntry),
+	}
+	// A mapped source is parsed in place, so that strings refer to it.
+	mapped, _ := d.r.(*MappedFile)
+	var readers []io.Reader
+	var pieces [][]byte
+	section := func(start, end int64) {
+		if mapped != nil {
+			pieces = append(pieces, mapped.data[start:end])
+		} else {
+			readers = append(readers, io.NewSectionReader(d.r, start, end-start))
+		}
+	}
+	offset := e.start
+	synthetic := 0
+	for _, child := range e.children {
+		section(offset, child.start)
+		synthetic += int(child.start - offset)
+		c.placeholders[synthetic] = child
+		placeholder := "{}"
+		if child.kind == '[' {
+			placeholder = "[]"
+		}
+		if mapped != nil {
+			pieces = append(pieces, []byte(placeholder))
+		} else {
+			readers = append(readers, strings.NewReader(placeholder))
+		}
+		synthetic += len(placeholder)
+		offset = child.end
+	}
+	section(offset, e.end)
+
+	var parser *Parser
+	if mapped != nil {
+		parser = newPiecedParser(pieces, c)
+	} else {
+		parser = NewParser(io.MultiReader(readers...), c)
+	}
+	c.parser = parser
+	if err := parser.Parse(); err != nil {
+		return nil, err
+	}
+	if len(c.stack) != 1 {
+		return nil, fmt.Errorf("Internal logic error: %d", len(c.stack))
+	}
+	return c.pop(), nil
+}
+
+// load returns the decoded container for e.
+func (d *lazyDocument) load(e *lazyEntry) (jsonValue, error) {
+	if elem, ok := d.cache[e]; ok {
+		d.lru.MoveToFront(elem)
+		return elem.Value.(*lazyCacheItem).value, nil
+	}
+	v, err := d.decode(e)
+	if err != nil {
+		return nil, err
+	}
+	d.cache[e] = d.lru.PushFront(&lazyCacheItem{entry: e, value: v})
+	if d.lru.Len() > lazyCacheSize {
+		oldest := d.lru.Back()
+		d.lru.Remove(oldest)
+		delete(d.cache, oldest.Value.(*lazyCacheItem).entry)
+		d.evictions++
+	}
+	return v, nil
+}
+
+// collect drops the symbols which are no longer used by the top level, the
+// cached containers or roots, so that the symbol table doesn't keep every
+// string ever decoded. It only does the work once a cache's worth of
+// containers has been evicted. Values which aren't reachable from any of
+// these must not be used afterwards.
+func (d *lazyDocument) collect(roots ...jsonValue) {
+	if d.evictions < lazyCacheSize {
+		return
+	}
+	d.evictions = 0
+	used := make(map[uint]bool)
+	seen := make(map[jsonValue]bool)
+	var mark func(v jsonValue)
+	mark = func(v jsonValue) {
+		switch value := v.(type) {
+		case *objectValue:
+			if seen[value] {
+				return
+			}
+			seen[value] = true
+			for _, id := range value.keys {
+				used[id] = true
+				mark(value.props[id])
+			}
+		case *arrayValue:
+			if seen[value] {
+				return
+			}
+			seen[value] = true
+			for _, e := range value.elems {
+				mark(e)
+			}
+		case *stringValue:
+			used[value.id] = true
+		}
+	}
+	mark(d.toplevel)
+	for elem := d.lru.Front(); elem != nil; elem = elem.Next() {
+		mark(elem.Value.(*lazyCacheItem).value)
+	}
+	for _, v := range roots {
+		mark(v)
+	}
+	for id, s := range d.symtab {
+		if !used[id] {
+			delete(d.symtab, id)
+			delete(d.inverted, s)
+		}
+	}
+}
+
+// resolve returns v, decoding it first if it is a lazyValue.
+func (d *lazyDocument) resolve(v jsonValue) (jsonValue, error) {
+	if lazy, ok := v.(*lazyValue); ok {
+		return d.load(lazy.entry)
+	}
+	return v, nil
+}
@@ -0,0 +1,138 @@
+package jsontools
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLazyDocument(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`{"small": {"a": [1, true, "x"]}, "big": [`)
+	for n := 0; n < 10000; n++ {
+		if n > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"id": %d, "name": "item \"%d\""}`, n, n)
+	}
+	b.WriteString(`], "tail": null}`)
+	src := b.String()
+
+	doc, err := newLazyDocument(strings.NewReader(src), int64(len(src)))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10002), doc.numObjects)
+	assert.Equal(t, int64(2), doc.numArrays)
+	assert.Equal(t, int64(20004), doc.numPrimitives)
+
+	root := doc.toplevel.(*objectValue)
+	big := root.props[doc.symtabMaker.getId("big")]
+	assert.IsType(t, &lazyValue{}, big)
+	assert.Equal(t, "[Array]", big.ToString())
+	assert.IsType(t, &objectValue{}, root.props[doc.symtabMaker.getId("small")])
+
+	v, err := doc.resolve(big)
+	assert.Nil(t, err)
+	arr := v.(*arrayValue)
+	assert.Equal(t, 10000, len(arr.elems))
+	assert.Equal(t, `{"id":9999,"name":"item \"9999\""}`, doc.encodeValue(arr.elems[9999]))
+
+	again, _ := doc.resolve(big)
+	assert.True(t, v == again)
+
+	eager, err := Decode(strings.NewReader(src))
+	assert.Nil(t, err)
+	assert.Equal(t, eager.encodeValue(eager.toplevel), doc.encodeValue(resolveMembers(doc, root)))
+	assert.Equal(t, eager.encodeValue(eager.toplevel), doc.encodeValue(root))
+
+	i, err := NewLazyInspector(strings.NewReader(src), int64(len(src)))
+	assert.Nil(t, err)
+	i.doCommand(`set big/3/name "renamed"`)
+	d, err := i.changes()
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(d.Entries)) {
+		assert.Equal(t, ".big[3].name", d.Entries[0].Path())
+	}
+	expected := mustInspector(t, src)
+	expected.doCommand(`set big/3/name "renamed"`)
+	assert.Equal(t, expected.json.encodeValue(expected.root()), i.json.encodeValue(i.root()))
+}
+
+func TestLazyDocumentCollect(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`{"big": [`)
+	for n := 0; n < 10000; n++ {
+		if n > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"id": %d, "name": "item %d"}`, n, n)
+	}
+	b.WriteString(`]}`)
+	src := b.String()
+	doc, err := newLazyDocument(strings.NewReader(src), int64(len(src)))
+	assert.Nil(t, err)
+	big := doc.toplevel.(*objectValue).props[doc.symtabMaker.getId("big")]
+	v, err := doc.resolve(big)
+	assert.Nil(t, err)
+	expected := doc.encodeValue(v)
+	assert.Equal(t, 10003, len(doc.symtab))
+
+	// evict empties the cache as if other containers had been loaded.
+	evict := func() {
+		doc.cache = make(map[*lazyEntry]*list.Element)
+		doc.lru.Init()
+		doc.evictions = lazyCacheSize
+	}
+	evict()
+	doc.collect(v)
+	assert.Equal(t, 10003, len(doc.symtab))
+	assert.Equal(t, expected, doc.encodeValue(v))
+
+	evict()
+	doc.collect()
+	assert.Equal(t, 1, len(doc.symtab))
+	_, ok := doc.lookup("item 5")
+	assert.False(t, ok)
+	again, err := doc.resolve(big)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, doc.encodeValue(again))
+
+	i, err := NewLazyInspector(strings.NewReader(src), int64(len(src)))
+	assert.Nil(t, err)
+	assert.Nil(t, i.cd("big/9999"))
+	i.lazy.evictions = lazyCacheSize
+	assert.Equal(t, "id: 9999.000000\nname: item 9999\n", commandOutput(t, i, "ls"))
+}
+
+func TestLazyInspectorErrors(t *testing.T) {
+	src := `{"big": [` + strings.Repeat(`"abcdefghijklmnop",`, 5000) + `0]}`
+	i, err := NewLazyInspector(strings.NewReader(src), int64(len(src)))
+	assert.Nil(t, err)
+	i.lazy.r = strings.NewReader(src[:100])
+	i.out = io.Discard
+	assert.NotNil(t, i.cd("big"))
+	assert.Equal(t, "/", i.pwd())
+	for _, command := range []string{"show", "tree", "find 0", "du", "compare big"} {
+		assert.NotNil(t, i.doCommand(command), command)
+	}
+}
+
+// resolveMembers returns root with its lazy members decoded.
+func resolveMembers(doc *lazyDocument, root *objectValue) jsonValue {
+	obj := &objectValue{props: make(map[uint]jsonValue)}
+	for _, id := range root.keys {
+		v, _ := doc.resolve(root.props[id])
+		obj.set(id, v)
+	}
+	return obj
+}
+
+func TestLazyDocumentErrors(t *testing.T) {
+	for _, src := range []string{`"x"`, `{"a": [}`, `{"a": "b`, `[1, 2`} {
+		_, err := newLazyDocument(strings.NewReader(src), int64(len(src)))
+		assert.NotNil(t, err, src)
+	}
+}
@@ -0,0 +1,224 @@
+package jsontools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+type ArrayMergeStrategy int
+
+const (
+	// ArrayReplace replaces an array with the later one.
+	ArrayReplace ArrayMergeStrategy = iota
+	// ArrayConcat appends the elements of the later array.
+	ArrayConcat
+	// ArrayMergeByKey merges objects whose key members are equal and
+	// appends the rest.
+	ArrayMergeByKey
+)
+
+type MergeOptions struct {
+	Arrays ArrayMergeStrategy
+	// Key names the member identifying elements for ArrayMergeByKey.
+	Key string
+	// NullDeletes removes members whose later value is null, as in
+	// JSON Merge Patch (RFC 7396).
+	NullDeletes bool
+}
+
+// Merger merges documents into a single one, remembering which
+// document each value came from.
+type Merger struct {
+	opts    MergeOptions
+	result  *decodeResult
+	names   []string
+	origins map[jsonValue]int
+}
+
+func NewMerger(opts *MergeOptions) *Merger {
+	m := &Merger{
+		result: &decodeResult{
+			symtab: make(symbolTable),
+		},
+		origins: make(map[jsonValue]int),
+	}
+	if opts != nil {
+		m.opts = *opts
+	}
+	return m
+}
+
+// Add merges doc, named name, on top of the documents added so far.
+func (m *Merger) Add(name string, doc *decodeResult) {
+	m.names = append(m.names, name)
+	if m.result.toplevel == nil {
+		// Only later documents are patches, so the first keeps its nulls.
+		m.result.toplevel = m.result.importValue(doc, doc.toplevel)
+		m.markOrigin(m.result.toplevel)
+		return
+	}
+	m.result.toplevel = m.merge(m.result.toplevel, doc, doc.toplevel)
+}
+
+// Result returns the merged document.
+func (m *Merger) Result() *decodeResult {
+	return m.result
+}
+
+// importValue copies v into the result, recording the current document
+// as the origin of every primitive and empty container in it.
+func (m *Merger) importValue(src *decodeResult, v jsonValue) jsonValue {
+	if m.opts.NullDeletes {
+		if obj, ok := v.(*objectValue); ok {
+			return m.merge(&objectValue{props: make(map[uint]jsonValue)}, src, obj)
+		}
+	}
+	value := m.result.importValue(src, v)
+	m.markOrigin(value)
+	return value
+}
+
+func (m *Merger) markOrigin(v jsonValue) {
+	origin := len(m.names) - 1
+	switch value := v.(type) {
+	case *objectValue:
+		if len(value.keys) == 0 {
+			m.origins[v] = origin
+		}
+		for _, p := range value.props {
+			m.markOrigin(p)
+		}
+	case *arrayValue:
+		if len(value.elems) == 0 {
+			m.origins[v] = origin
+		}
+		for _, e := range value.elems {
+			m.markOrigin(e)
+		}
+	default:
+		m.origins[v] = origin
+	}
+}
+
+func isNull(v jsonValue) bool {
+	l, ok := v.(*literalValue)
+	return ok && l.value == Null
+}
+
+func (m *Merger) merge(dst jsonValue, src *decodeResult, v jsonValue) jsonValue {
+	switch value := v.(type) {
+	case *objectValue:
+		obj, ok := dst.(*objectValue)
+		if !ok {
+			if !m.opts.NullDeletes {
+				return m.importValue(src, v)
+			}
+			obj = &objectValue{props: make(map[uint]jsonValue)}
+		}
+		if len(obj.keys) == 0 {
+			delete(m.origins, obj)
+		}
+		for _, id := range value.keys {
+			member := value.props[id]
+			// Members match by name however it is escaped.
+			name, exists := patchMemberId(m.result, obj, src.text(id))
+			if m.opts.NullDeletes && isNull(member) {
+				if exists {
+					obj.remove(name)
+				}
+				continue
+			}
+			if exists {
+				obj.set(name, m.merge(obj.props[name], src, member))
+			} else {
+				obj.set(m.result.intern(src.str(id)), m.importValue(src, member))
+			}
+		}
+		if len(obj.keys) == 0 {
+			m.markOrigin(obj)
+		}
+		return obj
+	case *arrayValue:
+		arr, ok := dst.(*arrayValue)
+		if !ok || m.opts.Arrays == ArrayReplace {
+			return m.importValue(src, v)
+		}
+		if len(value.elems) > 0 {
+			delete(m.origins, arr)
+		}
+		for _, e := range value.elems {
+			if m.opts.Arrays == ArrayMergeByKey {
+				if i := m.findByKey(arr, src, e); i >= 0 {
+					arr.elems[i] = m.merge(arr.elems[i], src, e)
+					continue
+				}
+			}
+			arr.elems = append(arr.elems, m.importValue(src, e))
+		}
+		return arr
+	}
+	return m.importValue(src, v)
+}
+
+// findByKey returns the index of the object in arr whose key member
+// equals that of e, or -1.
+func (m *Merger) findByKey(arr *arrayValue, src *decodeResult, e jsonValue) int {
+	obj, ok := e.(*objectValue)
+	if !ok {
+		return -1
+	}
+	key := patchMember(src, obj, m.opts.Key)
+	if key == nil {
+		return -1
+	}
+	d := &differ{a: m.result, b: src}
+	for i, candidate := range arr.elems {
+		c, ok := candidate.(*objectValue)
+		if !ok {
+			continue
+		}
+		if k := patchMember(m.result, c, m.opts.Key); k != nil && d.equal(valuePath{}, k, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// WriteOrigins prints every primitive value of the result with the name
+// of the document it came from.
+func (m *Merger) WriteOrigins(w io.Writer) error {
+	var buf bytes.Buffer
+	m.writeOrigins(&buf, valuePath{}, m.result.toplevel)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func (m *Merger) writeOrigins(buf *bytes.Buffer, path valuePath, v jsonValue) {
+	if origin, ok := m.origins[v]; ok {
+		fmt.Fprintf(buf, "%s: %s  (%s)\n", displayPath(path),
+			m.result.encodeValue(v), m.names[origin])
+		return
+	}
+	switch value := v.(type) {
+	case *objectValue:
+		for _, id := range value.keys {
+			m.writeOrigins(buf, path.child(memberSegment(m.result.str(id))), value.props[id])
+		}
+	case *arrayValue:
+		for i, e := range value.elems {
+			m.writeOrigins(buf, path.child(indexSegment(i)), e)
+		}
+	}
+}
+
+// ApplyMergePatch applies a JSON Merge Patch (RFC 7396) to the document.
+func (r *decodeResult) ApplyMergePatch(patch *decodeResult) {
+	m := &Merger{
+		opts:    MergeOptions{NullDeletes: true},
+		result:  r,
+		names:   []string{""},
+		origins: make(map[jsonValue]int),
+	}
+	r.toplevel = m.merge(r.toplevel, patch, patch.toplevel)
+}
@@ -0,0 +1,96 @@
+package jsontools
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	cases := []struct {
+		target, patch, expected string
+	}{
+		{`{"a": "b"}`, `{"a": "c"}`, `{"a":"c"}`},
+		{`{"a": "b"}`, `{"b": "c"}`, `{"a":"b","b":"c"}`},
+		{`{"a": "b"}`, `{"a": null}`, `{}`},
+		{`{"a": "b", "b": "c"}`, `{"a": null}`, `{"b":"c"}`},
+		{`{"a": ["b"]}`, `{"a": "c"}`, `{"a":"c"}`},
+		{`{"a": "c"}`, `{"a": ["b"]}`, `{"a":["b"]}`},
+		{`{"a": {"b": "c"}}`, `{"a": {"b": "d", "c": null}}`, `{"a":{"b":"d"}}`},
+		{`{"a": [{"b": "c"}]}`, `{"a": [1]}`, `{"a":[1]}`},
+		{`{"e": null}`, `{"a": 1}`, `{"e":null,"a":1}`},
+		{`[1, 2]`, `{"a": "b", "c": null}`, `{"a":"b"}`},
+		{`{}`, `{"a": {"bb": {"ccc": null}}}`, `{"a":{"bb":{}}}`},
+		{`{"A": 1, "b": 2}`, `{"\u0041": 3, "\u0062": null}`, `{"A":3}`},
+	}
+	for _, c := range cases {
+		r := mustDecode(t, c.target)
+		r.ApplyMergePatch(mustDecode(t, c.patch))
+		assert.Equal(t, c.expected, r.encodeValue(r.toplevel), c.patch)
+	}
+}
+
+func merge(t *testing.T, opts *MergeOptions, docs ...string) *Merger {
+	m := NewMerger(opts)
+	for i, doc := range docs {
+		m.Add(string(rune('a'+i))+".json", mustDecode(t, doc))
+	}
+	return m
+}
+
+func TestMerger(t *testing.T) {
+	base := `{"name": "app", "db": {"host": "db", "port": 5432}, "tags": ["x"], "svc": [{"id": 1, "on": true}]}`
+	override := `{"db": {"host": "localhost"}, "tags": ["y"], "svc": [{"id": 1, "on": false}, {"id": 2}]}`
+
+	m := merge(t, nil, base, override)
+	r := m.Result()
+	assert.Equal(t,
+		`{"name":"app","db":{"host":"localhost","port":5432},"tags":["y"],"svc":[{"id":1,"on":false},{"id":2}]}`,
+		r.encodeValue(r.toplevel))
+
+	m = merge(t, &MergeOptions{Arrays: ArrayConcat}, base, override)
+	r = m.Result()
+	assert.Equal(t,
+		`{"name":"app","db":{"host":"localhost","port":5432},"tags":["x","y"],"svc":[{"id":1,"on":true},{"id":1,"on":false},{"id":2}]}`,
+		r.encodeValue(r.toplevel))
+
+	m = merge(t, &MergeOptions{Arrays: ArrayMergeByKey, Key: "id"}, base, override)
+	r = m.Result()
+	assert.Equal(t,
+		`{"name":"app","db":{"host":"localhost","port":5432},"tags":["x","y"],"svc":[{"id":1,"on":false},{"id":2}]}`,
+		r.encodeValue(r.toplevel))
+
+	w := new(bytes.Buffer)
+	assert.Nil(t, m.WriteOrigins(w))
+	assert.Equal(t, `.name: "app"  (a.json)
+.db.host: "localhost"  (b.json)
+.db.port: 5432  (a.json)
+.tags[0]: "x"  (a.json)
+.tags[1]: "y"  (b.json)
+.svc[0].id: 1  (b.json)
+.svc[0].on: false  (b.json)
+.svc[1].id: 2  (b.json)
+`, w.String())
+}
+
+func TestMergerNullDeletes(t *testing.T) {
+	m := merge(t, &MergeOptions{NullDeletes: true}, `{"a": null, "b": {"c": null}}`, `{}`)
+	r := m.Result()
+	assert.Equal(t, `{"a":null,"b":{"c":null}}`, r.encodeValue(r.toplevel))
+
+	m = merge(t, &MergeOptions{NullDeletes: true}, `{"a": null, "b": {"c": null, "d": 1}}`, `{"b": {"d": null, "e": {"f": null}}}`)
+	r = m.Result()
+	assert.Equal(t, `{"a":null,"b":{"c":null,"e":{}}}`, r.encodeValue(r.toplevel))
+}
+
+func TestMergerEscapedNames(t *testing.T) {
+	m := merge(t, nil, `{"A": 1}`, `{"\u0041": 2}`)
+	r := m.Result()
+	assert.Equal(t, `{"A":2}`, r.encodeValue(r.toplevel))
+
+	m = merge(t, &MergeOptions{Arrays: ArrayMergeByKey, Key: "id"},
+		`[{"id": 1, "a": 1}]`, `[{"\u0069d": 1, "b": 2}]`)
+	r = m.Result()
+	assert.Equal(t, `[{"id":1,"a":1,"b":2}]`, r.encodeValue(r.toplevel))
+}
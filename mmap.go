@@ -0,0 +1,109 @@
+package jsontools
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+var errNotMappable = errors.New("file can't be mapped")
+
+// MappedFile is the contents of a file mapped into memory by MapFile.
+// Documents and indexes built from it refer to the mapping rather than
+// copying their strings, so they must not be used after Close, and the
+// file must not be changed while it is mapped.
+type MappedFile struct {
+	data []byte
+}
+
+// MapFile maps the contents of f into memory. It fails for anything other
+// than a non-empty regular file positioned at its start, such as stdin
+// connected to a pipe, in which case f should be read instead.
+func MapFile(f *os.File) (*MappedFile, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() || info.Size() == 0 || int64(int(info.Size())) != info.Size() {
+		return nil, errNotMappable
+	}
+	if offset, err := f.Seek(0, io.SeekCurrent); err != nil || offset != 0 {
+		return nil, errNotMappable
+	}
+	data, err := mmap(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+	return &MappedFile{data: data}, nil
+}
+
+// Bytes returns the mapped contents.
+func (m *MappedFile) Bytes() []byte {
+	return m.data
+}
+
+// Size returns the length of the mapped contents.
+func (m *MappedFile) Size() int64 {
+	return int64(len(m.data))
+}
+
+// ReadAt implements io.ReaderAt, copying from the mapping.
+func (m *MappedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Decode decodes the mapped document. Its strings refer to the mapping.
+func (m *MappedFile) Decode() (*decodeResult, error) {
+	c := newDecoderClient(false)
+	return c.decode(newMappedParser(m.data, c))
+}
+
+// NewIndexer returns an Indexer for the mapped document. The identifiers
+// of the index refer to the mapping.
+func (m *MappedFile) NewIndexer() *Indexer {
+	client := newIndexerClient()
+	return newIndexer(newMappedParser(m.data, client), client)
+}
+
+// NewInspector returns an Inspector for the mapped document, which
+// releases the mapping when it is closed.
+func (m *MappedFile) NewInspector() (*Inspector, error) {
+	json, err := m.Decode()
+	if err != nil {
+		return nil, err
+	}
+	i := newInspector(json)
+	i.mapped = m
+	return i, nil
+}
+
+// NewLazyInspector is like NewInspector, but decodes the document on
+// demand like the function NewLazyInspector.
+func (m *MappedFile) NewLazyInspector() (*Inspector, error) {
+	i, err := NewLazyInspector(m, m.Size())
+	if err != nil {
+		return nil, err
+	}
+	i.mapped = m
+	return i, nil
+}
+
+// Close releases the mapping. It is safe to call more than once.
+func (m *MappedFile) Close() error {
+	if m.data == nil {
+		return nil
+	}
+	data := m.data
+	m.data = nil
+	return munmap(data)
+}
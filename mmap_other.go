@@ -0,0 +1,13 @@
+//go:build !unix
+
+package jsontools
+
+import "os"
+
+func mmap(f *os.File, size int) ([]byte, error) {
+	return nil, errNotMappable
+}
+
+func munmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,115 @@
+package jsontools
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMappedDecode(t *testing.T) {
+	src := `{"name": "a\"b", "list": [1, -2.5, true, "", {"k": "v"}]}`
+	name := filepath.Join(t.TempDir(), "doc.json")
+	assert.Nil(t, os.WriteFile(name, []byte(src), 0644))
+	f, err := os.Open(name)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	m, err := MapFile(f)
+	assert.Nil(t, err)
+	assert.Equal(t, src, string(m.Bytes()))
+	buf := make([]byte, 8)
+	n, err := m.ReadAt(buf, int64(len(src)-4))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, src[len(src)-4:], string(buf[:n]))
+
+	mapped, err := m.Decode()
+	assert.Nil(t, err)
+	expected, err := Decode(strings.NewReader(src))
+	assert.Nil(t, err)
+	assert.Equal(t, expected.encodeValue(expected.toplevel), mapped.encodeValue(mapped.toplevel))
+	assert.Equal(t, expected.numPrimitives, mapped.numPrimitives)
+	index, err := m.NewIndexer().CreateIndex()
+	assert.Nil(t, err)
+	entries, err := index.Match("v")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Nil(t, m.Close())
+	assert.Nil(t, m.Close())
+
+	read, err := Decode(f)
+	assert.Nil(t, err)
+	assert.Equal(t, expected.encodeValue(expected.toplevel), read.encodeValue(read.toplevel))
+
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	_, err = MapFile(r)
+	assert.NotNil(t, err)
+	go func() {
+		w.WriteString(src)
+		w.Close()
+	}()
+	piped, err := Decode(r)
+	assert.Nil(t, err)
+	assert.Equal(t, expected.encodeValue(expected.toplevel), piped.encodeValue(piped.toplevel))
+}
+
+func TestMappedInspector(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`{"small": {"a": [1, true, "x"]}, "big": [`)
+	for n := 0; n < 10000; n++ {
+		if n > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"id": %d, "name": "item \"%d\""}`, n, n)
+	}
+	b.WriteString(`], "tail": null}`)
+	src := b.String()
+	name := filepath.Join(t.TempDir(), "doc.json")
+	assert.Nil(t, os.WriteFile(name, []byte(src), 0644))
+	expected, err := Decode(strings.NewReader(src))
+	assert.Nil(t, err)
+
+	f, err := os.Open(name)
+	assert.Nil(t, err)
+	m, err := MapFile(f)
+	f.Close()
+	assert.Nil(t, err)
+	i, err := m.NewInspector()
+	assert.Nil(t, err)
+	assert.Equal(t, expected.encodeValue(expected.toplevel), i.json.encodeValue(i.json.toplevel))
+	assert.Nil(t, i.Close())
+
+	f, err = os.Open(name)
+	assert.Nil(t, err)
+	m, err = MapFile(f)
+	f.Close()
+	assert.Nil(t, err)
+	i, err = m.NewLazyInspector()
+	assert.Nil(t, err)
+	root := i.lazy.toplevel.(*objectValue)
+	big := root.props[i.lazy.symtabMaker.getId("big")]
+	assert.IsType(t, &lazyValue{}, big)
+	v, err := i.lazy.resolve(big)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":9999,"name":"item \"9999\""}`, i.lazy.encodeValue(v.(*arrayValue).elems[9999]))
+	assert.Equal(t, expected.encodeValue(expected.toplevel), i.lazy.encodeValue(root))
+	assert.Nil(t, i.Close())
+}
+
+func TestMappedIndexFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"name": "alpha"}`), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"name": "beta"}`), 0644))
+	index, err := IndexFiles([]string{filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(index.mapped))
+	entries, err := index.Match("beta")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Nil(t, index.Close())
+}
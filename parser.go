@@ -1,9 +1,12 @@
 package jsontools
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"sort"
 	"text/scanner"
+	"unsafe"
 )
 
 type Literal int
@@ -61,6 +64,13 @@ func (p *ParserClientBase) LiteralValue(Literal) {}
 type Parser struct {
 	s *scanner.Scanner
 	c ParserClient
+	// data is the whole input when it is held in memory, in which case
+	// strings passed to the client refer to it rather than being copied.
+	data []byte
+	// pieces make up the input instead when it is pieced together from
+	// memory, and offsets holds where each of them starts.
+	pieces  [][]byte
+	offsets []int
 }
 
 func NewParser(r io.Reader, c ParserClient) *Parser {
@@ -76,9 +86,36 @@ func NewParser(r io.Reader, c ParserClient) *Parser {
 	}
 }
 
+// newMappedParser returns a parser for input which stays in memory, and
+// unchanged, for as long as the strings passed to c are in use.
+func newMappedParser(data []byte, c ParserClient) *Parser {
+	p := NewParser(bytes.NewReader(data), c)
+	p.data = data
+	return p
+}
+
+// newPiecedParser is like newMappedParser for input which is the
+// concatenation of pieces.
+func newPiecedParser(pieces [][]byte, c ParserClient) *Parser {
+	readers := make([]io.Reader, len(pieces))
+	offsets := make([]int, len(pieces))
+	offset := 0
+	for n, piece := range pieces {
+		readers[n] = bytes.NewReader(piece)
+		offsets[n] = offset
+		offset += len(piece)
+	}
+	p := NewParser(io.MultiReader(readers...), c)
+	p.pieces = pieces
+	p.offsets = offsets
+	return p
+}
+
 type ParserPosition struct {
 	Line   int
 	Column int
+	// Offset is the byte offset from the start of the input.
+	Offset int
 }
 
 func (p *ParserPosition) String() string {
@@ -90,6 +127,17 @@ func (p *Parser) CurrentPos() ParserPosition {
 	return ParserPosition{
 		Line:   pos.Line,
 		Column: pos.Column,
+		Offset: pos.Offset,
+	}
+}
+
+// TokenPos returns the position where the most recently scanned token
+// starts.
+func (p *Parser) TokenPos() ParserPosition {
+	return ParserPosition{
+		Line:   p.s.Position.Line,
+		Column: p.s.Position.Column,
+		Offset: p.s.Position.Offset,
 	}
 }
 
@@ -102,6 +150,25 @@ func (e *ParseError) Error() string {
 	return e.Pos.String() + ": " + e.Message
 }
 
+// tokenText returns the text of the last token.
+func (p *Parser) tokenText() string {
+	if p.data == nil && p.pieces == nil {
+		return p.s.TokenText()
+	}
+	start := p.s.Position.Offset
+	end := p.s.Pos().Offset
+	if p.data != nil {
+		return unsafe.String(&p.data[start], end-start)
+	}
+	n := sort.Search(len(p.pieces), func(n int) bool {
+		return p.offsets[n]+len(p.pieces[n]) > start
+	})
+	if n < len(p.pieces) && end <= p.offsets[n]+len(p.pieces[n]) {
+		return unsafe.String(&p.pieces[n][start-p.offsets[n]], end-start)
+	}
+	return p.s.TokenText()
+}
+
 func (p *Parser) createError(format string, args ...interface{}) error {
 	return &ParseError{
 		Message: fmt.Sprintf(format, args...),
@@ -140,7 +207,7 @@ func (p *Parser) parseObject() error {
 			return p.createError("expected string, but got %s ",
 				scanner.TokenString(tok))
 		}
-		p.c.StartMember(p.s.TokenText())
+		p.c.StartMember(p.tokenText())
 
 		tok = p.s.Scan()
 		if tok != ':' {
@@ -201,7 +268,7 @@ func (p *Parser) parseValue(tok rune) error {
 	} else if tok == '[' {
 		return p.parseArray()
 	} else if tok == scanner.String {
-		p.c.StringValue(p.s.TokenText())
+		p.c.StringValue(p.tokenText())
 		return nil
 	} else if tok == '-' {
 		tok = p.s.Scan()
@@ -0,0 +1,519 @@
+package jsontools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// PatchOp is a single operation of a JSON Patch (RFC 6902). Path and From
+// are JSON Pointers, with any escape sequences of the patch text resolved.
+type PatchOp struct {
+	Op   string
+	Path string
+	From string
+	// value belongs to doc.
+	value jsonValue
+	doc   *decodeResult
+}
+
+// Patch is a JSON Patch document.
+type Patch struct {
+	Ops []*PatchOp
+}
+
+// patchMemberId returns the symbol id of the member of obj named name,
+// however the name is escaped in the source.
+func patchMemberId(r *decodeResult, obj *objectValue, name string) (uint, bool) {
+	if id, ok := r.lookup(name); ok {
+		if _, ok := obj.props[id]; ok && r.text(id) == name {
+			return id, true
+		}
+	}
+	for _, id := range obj.keys {
+		if r.text(id) == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+func patchMember(r *decodeResult, obj *objectValue, name string) jsonValue {
+	id, ok := patchMemberId(r, obj, name)
+	if !ok {
+		return nil
+	}
+	return obj.props[id]
+}
+
+func patchString(r *decodeResult, obj *objectValue, name string) (string, bool) {
+	v, ok := patchMember(r, obj, name).(*stringValue)
+	if !ok {
+		return "", false
+	}
+	return r.text(v.id), true
+}
+
+// ParsePatch reads a JSON Patch document.
+func ParsePatch(r io.Reader) (*Patch, error) {
+	doc, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := doc.toplevel.(*arrayValue)
+	if !ok {
+		return nil, fmt.Errorf("patch must be an array of operations")
+	}
+	patch := &Patch{}
+	for n, e := range arr.elems {
+		obj, ok := e.(*objectValue)
+		if !ok {
+			return nil, fmt.Errorf("operation %d: not an object", n)
+		}
+		op := &PatchOp{doc: doc}
+		if op.Op, ok = patchString(doc, obj, "op"); !ok {
+			return nil, fmt.Errorf("operation %d: missing \"op\"", n)
+		}
+		if op.Path, ok = patchString(doc, obj, "path"); !ok {
+			return nil, fmt.Errorf("operation %d: missing \"path\"", n)
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.value = patchMember(doc, obj, "value"); op.value == nil {
+				return nil, fmt.Errorf("operation %d: missing \"value\"", n)
+			}
+		case "move", "copy":
+			if op.From, ok = patchString(doc, obj, "from"); !ok {
+				return nil, fmt.Errorf("operation %d: missing \"from\"", n)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("operation %d: unknown op %q", n, op.Op)
+		}
+		patch.Ops = append(patch.Ops, op)
+	}
+	return patch, nil
+}
+
+// Encode writes the patch as JSON text.
+func (p *Patch) Encode(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for n, op := range p.Ops {
+		if n > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `{"op":%s,"path":%s`, quoteString(op.Op), quoteString(op.Path))
+		if op.From != "" || op.Op == "move" || op.Op == "copy" {
+			fmt.Fprintf(&buf, `,"from":%s`, quoteString(op.From))
+		}
+		if op.value != nil {
+			buf.WriteString(`,"value":`)
+			buf.WriteString(op.doc.encodeValue(op.value))
+		}
+		buf.WriteString("}")
+	}
+	buf.WriteString("]\n")
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// cloneValue returns a deep copy of v within the same document.
+func cloneValue(v jsonValue) jsonValue {
+	switch value := v.(type) {
+	case *objectValue:
+		obj := &objectValue{
+			props: make(map[uint]jsonValue, len(value.keys)),
+			keys:  make([]uint, len(value.keys)),
+		}
+		copy(obj.keys, value.keys)
+		for id, m := range value.props {
+			obj.props[id] = cloneValue(m)
+		}
+		return obj
+	case *arrayValue:
+		arr := &arrayValue{
+			elems: make([]jsonValue, len(value.elems)),
+		}
+		for i, e := range value.elems {
+			arr.elems[i] = cloneValue(e)
+		}
+		return arr
+	case *stringValue:
+		return &stringValue{value.id}
+	case *numberValue:
+		return &numberValue{value.value, value.integer, value.raw}
+	case *literalValue:
+		return &literalValue{value.value}
+	}
+	return v
+}
+
+// patcher applies operations to a working copy of a document.
+type patcher struct {
+	doc  *decodeResult
+	root jsonValue
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	limit := length - 1
+	if allowEnd {
+		limit = length
+	}
+	if index > limit {
+		return 0, fmt.Errorf("array index %d out of range", index)
+	}
+	return index, nil
+}
+
+func (p *patcher) get(path valuePath) (jsonValue, error) {
+	v := p.root
+	for _, s := range path {
+		switch cur := v.(type) {
+		case *objectValue:
+			v = patchMember(p.doc, cur, s.name)
+			if v == nil {
+				return nil, fmt.Errorf("no member %q", s.name)
+			}
+		case *arrayValue:
+			index, err := arrayIndex(s.name, len(cur.elems), false)
+			if err != nil {
+				return nil, err
+			}
+			v = cur.elems[index]
+		default:
+			return nil, fmt.Errorf("cannot refer to %q in a primitive value", s.name)
+		}
+	}
+	return v, nil
+}
+
+func (p *patcher) add(path valuePath, value jsonValue) error {
+	if len(path) == 0 {
+		p.root = value
+		return nil
+	}
+	parent, err := p.get(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	last := path[len(path)-1].name
+	switch cur := parent.(type) {
+	case *objectValue:
+		id, ok := patchMemberId(p.doc, cur, last)
+		if !ok {
+			id = p.doc.intern(escapeString(last))
+		}
+		cur.set(id, value)
+	case *arrayValue:
+		index, err := arrayIndex(last, len(cur.elems), true)
+		if err != nil {
+			return err
+		}
+		cur.elems = append(cur.elems, nil)
+		copy(cur.elems[index+1:], cur.elems[index:])
+		cur.elems[index] = value
+	default:
+		return fmt.Errorf("cannot add %q to a primitive value", last)
+	}
+	return nil
+}
+
+func (p *patcher) remove(path valuePath) (jsonValue, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot remove the document root")
+	}
+	parent, err := p.get(path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1].name
+	switch cur := parent.(type) {
+	case *objectValue:
+		id, ok := patchMemberId(p.doc, cur, last)
+		if !ok {
+			return nil, fmt.Errorf("no member %q", last)
+		}
+		v := cur.props[id]
+		cur.remove(id)
+		return v, nil
+	case *arrayValue:
+		index, err := arrayIndex(last, len(cur.elems), false)
+		if err != nil {
+			return nil, err
+		}
+		v := cur.elems[index]
+		cur.elems = append(cur.elems[:index], cur.elems[index+1:]...)
+		return v, nil
+	}
+	return nil, fmt.Errorf("cannot remove %q from a primitive value", last)
+}
+
+// replace sets the existing value at path to value, keeping the position
+// of an object member.
+func (p *patcher) replace(path valuePath, value jsonValue) error {
+	if len(path) == 0 {
+		p.root = value
+		return nil
+	}
+	parent, err := p.get(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	last := path[len(path)-1].name
+	switch cur := parent.(type) {
+	case *objectValue:
+		id, ok := patchMemberId(p.doc, cur, last)
+		if !ok {
+			return fmt.Errorf("no member %q", last)
+		}
+		cur.set(id, value)
+		return nil
+	case *arrayValue:
+		index, err := arrayIndex(last, len(cur.elems), false)
+		if err != nil {
+			return err
+		}
+		cur.elems[index] = value
+		return nil
+	}
+	return fmt.Errorf("cannot replace %q in a primitive value", last)
+}
+
+// within reports whether path lies strictly inside from. Unlike hasPrefix,
+// it compares the segments literally, so that "*" is just a member name.
+func within(path, from valuePath) bool {
+	if len(path) <= len(from) {
+		return false
+	}
+	for i, s := range from {
+		if s != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *patcher) apply(op *PatchOp) error {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return err
+	}
+	switch op.Op {
+	case "add":
+		return p.add(path, p.doc.importValue(op.doc, op.value))
+	case "remove":
+		_, err := p.remove(path)
+		return err
+	case "replace":
+		return p.replace(path, p.doc.importValue(op.doc, op.value))
+	case "move":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return err
+		}
+		if op.From == op.Path {
+			// from must exist even though nothing moves.
+			_, err := p.get(from)
+			return err
+		}
+		if within(path, from) {
+			return fmt.Errorf("cannot move %s into itself", op.From)
+		}
+		v, err := p.remove(from)
+		if err != nil {
+			return err
+		}
+		return p.add(path, v)
+	case "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return err
+		}
+		v, err := p.get(from)
+		if err != nil {
+			return err
+		}
+		return p.add(path, cloneValue(v))
+	case "test":
+		v, err := p.get(path)
+		if err != nil {
+			return err
+		}
+		d := &differ{a: p.doc, b: op.doc}
+		if !d.equal(valuePath{}, v, op.value) {
+			return fmt.Errorf("test failed at %s", op.Path)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown op %q", op.Op)
+}
+
+// ApplyPatch applies all operations of p, or none of them if any
+// operation fails.
+func (r *decodeResult) ApplyPatch(p *Patch) error {
+	pt := &patcher{
+		doc:  r,
+		root: cloneValue(r.toplevel),
+	}
+	for n, op := range p.Ops {
+		if err := pt.apply(op); err != nil {
+			return fmt.Errorf("operation %d (%s %s): %v", n, op.Op, op.Path, err)
+		}
+	}
+	r.toplevel = pt.root
+	return nil
+}
+
+// Arrays larger than this (in elements of a times elements of b) are
+// compared position by position rather than by longest common
+// subsequence.
+const maxPatchLCSSize = 1 << 22
+
+type patchBuilder struct {
+	d   *differ
+	ops []*PatchOp
+}
+
+func (b *patchBuilder) emit(op string, path valuePath, value jsonValue) {
+	b.ops = append(b.ops, &PatchOp{
+		Op:    op,
+		Path:  path.Pointer(),
+		value: value,
+		doc:   b.d.b,
+	})
+}
+
+func (b *patchBuilder) diff(path valuePath, va, vb jsonValue) {
+	switch a := va.(type) {
+	case *objectValue:
+		if obj, ok := vb.(*objectValue); ok {
+			b.diffObjects(path, a, obj)
+			return
+		}
+	case *arrayValue:
+		if arr, ok := vb.(*arrayValue); ok {
+			b.diffArrays(path, a, arr)
+			return
+		}
+	}
+	if !b.d.equal(path, va, vb) {
+		b.emit("replace", path, vb)
+	}
+}
+
+func (b *patchBuilder) diffObjects(path valuePath, a, obj *objectValue) {
+	membersB := membersByName(b.d.b, obj)
+	for _, id := range a.keys {
+		child := path.child(memberSegment(b.d.a.str(id)))
+		if vb, ok := membersB[b.d.a.text(id)]; ok {
+			b.diff(child, a.props[id], vb)
+		} else {
+			b.emit("remove", child, nil)
+		}
+	}
+	membersA := membersByName(b.d.a, a)
+	for _, id := range obj.keys {
+		if _, ok := membersA[b.d.b.text(id)]; !ok {
+			b.emit("add", path.child(memberSegment(b.d.b.str(id))), obj.props[id])
+		}
+	}
+}
+
+// diffArrays aligns the elements of a and arr by their longest common
+// subsequence and turns the gaps into replace, remove and add operations.
+func (b *patchBuilder) diffArrays(path valuePath, a, arr *arrayValue) {
+	ea, eb := a.elems, arr.elems
+	eq := func(i, j int) bool {
+		return b.d.equal(path, ea[i], eb[j])
+	}
+
+	prefix := 0
+	for prefix < len(ea) && prefix < len(eb) && eq(prefix, prefix) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(ea)-prefix && suffix < len(eb)-prefix &&
+		eq(len(ea)-1-suffix, len(eb)-1-suffix) {
+		suffix++
+	}
+	n := len(ea) - prefix - suffix
+	m := len(eb) - prefix - suffix
+
+	// keep[i] is the index in b matched with element prefix+i of a, or -1.
+	keep := make([]int, n)
+	for i := range keep {
+		keep[i] = -1
+	}
+	if n > 0 && m > 0 && n*m <= maxPatchLCSSize {
+		lcs := make([][]int, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if eq(prefix+i, prefix+j) {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		for i, j := 0, 0; i < n && j < m; {
+			if eq(prefix+i, prefix+j) {
+				keep[i] = j
+				i++
+				j++
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				i++
+			} else {
+				j++
+			}
+		}
+	}
+
+	// index is the position in the array as patched so far.
+	index := prefix
+	i, j := 0, 0
+	flush := func(nextI, nextJ int) {
+		for ; i < nextI && j < nextJ; i, j = i+1, j+1 {
+			b.diff(path.child(indexSegment(index)), ea[prefix+i], eb[prefix+j])
+			index++
+		}
+		for ; i < nextI; i++ {
+			b.emit("remove", path.child(indexSegment(index)), nil)
+		}
+		for ; j < nextJ; j++ {
+			b.emit("add", path.child(indexSegment(index)), eb[prefix+j])
+			index++
+		}
+	}
+	for k := 0; k < n; k++ {
+		if keep[k] < 0 {
+			continue
+		}
+		flush(k, keep[k])
+		i, j = i+1, j+1
+		index++
+	}
+	flush(n, m)
+}
+
+// CreatePatch returns a patch that turns a into b.
+func CreatePatch(a, b *decodeResult) *Patch {
+	builder := &patchBuilder{
+		d: &differ{a: a, b: b},
+	}
+	builder.diff(valuePath{}, a.toplevel, b.toplevel)
+	return &Patch{Ops: builder.ops}
+}
@@ -0,0 +1,111 @@
+package jsontools
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func applyPatch(t *testing.T, doc string, patch string) (string, error) {
+	r := mustDecode(t, doc)
+	p, err := ParsePatch(strings.NewReader(patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.ApplyPatch(p)
+	return r.encodeValue(r.toplevel), err
+}
+
+func TestApplyPatch(t *testing.T) {
+	cases := []struct {
+		doc, patch, expected string
+	}{
+		{`{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			`{"foo":"bar","baz":"qux"}`},
+		{`{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			`{"foo":["bar","qux","baz"]}`},
+		{`{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/-", "value": ["x"]}]`,
+			`{"foo":["bar",["x"]]}`},
+		{`{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`,
+			`{"foo":"bar"}`},
+		{`{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`,
+			`{"foo":["bar","baz"]}`},
+		{`{"baz": "qux", "foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			`{"baz":"boo","foo":"bar"}`},
+		{`{"a": [1, 2, 3]}`, `[{"op": "replace", "path": "/a/1", "value": {"b": 0}}]`,
+			`{"a":[1,{"b":0},3]}`},
+		{`{"a": 1}`, `[{"op": "replace", "path": "", "value": [2]}]`,
+			`[2]`},
+		{`{"a": 1}`, `[{"op": "add", "path": "", "value": "x"}]`,
+			`"x"`},
+		{`{"*": {"b": 1}, "c": {}}`, `[{"op": "move", "from": "/*", "path": "/c/d"}]`,
+			`{"c":{"d":{"b":1}}}`},
+		{`{"\u0041": 1, "q\"": 2}`, `[{"op": "replace", "path": "/A", "value": 3}, {"op": "add", "path": "/q\"", "value": 4}, {"op": "add", "path": "/a\\b", "value": 5}]`,
+			`{"\u0041":3,"q\"":4,"a\\b":5}`},
+		{`{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			`[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo": ["all", "grass", "cows", "eat"]}`,
+			`[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`},
+		{`{"a": {"b": [1]}}`, `[{"op": "copy", "from": "/a/b", "path": "/c"}, {"op": "add", "path": "/c/-", "value": 2}]`,
+			`{"a":{"b":[1]},"c":[1,2]}`},
+		{`{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			`[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+	}
+	for _, c := range cases {
+		actual, err := applyPatch(t, c.doc, c.patch)
+		assert.Nil(t, err, c.patch)
+		assert.Equal(t, c.expected, actual, c.patch)
+	}
+}
+
+func TestApplyPatchIsAtomic(t *testing.T) {
+	doc := `{"a": 1, "b": [1, 2]}`
+	for _, patch := range []string{
+		`[{"op": "remove", "path": "/a"}, {"op": "test", "path": "/b/0", "value": "1"}]`,
+		`[{"op": "add", "path": "/b/-", "value": 3}, {"op": "add", "path": "/x/y", "value": 1}]`,
+		`[{"op": "remove", "path": "/b/2"}]`,
+		`[{"op": "add", "path": "/b/01", "value": 0}]`,
+		`[{"op": "move", "from": "/b", "path": "/b/0"}]`,
+		`[{"op": "move", "from": "/c", "path": "/c"}]`,
+		`[{"op": "remove", "path": ""}]`,
+	} {
+		actual, err := applyPatch(t, doc, patch)
+		assert.Error(t, err, patch)
+		assert.Equal(t, `{"a":1,"b":[1,2]}`, actual, patch)
+	}
+}
+
+func TestCreatePatch(t *testing.T) {
+	cases := []struct {
+		a, b, expected string
+	}{
+		{`{"a": 1}`, `{"a": 1}`, `[]`},
+		{`{"a": 1, "b": 2}`, `{"a": 3, "c": 4}`,
+			`[{"op":"replace","path":"/a","value":3},{"op":"remove","path":"/b"},{"op":"add","path":"/c","value":4}]`},
+		{`[1, 2, 3]`, `[0, 1, 2, 3]`,
+			`[{"op":"add","path":"/0","value":0}]`},
+		{`[1, 2, 3, 4]`, `[1, 4]`,
+			`[{"op":"remove","path":"/1"},{"op":"remove","path":"/1"}]`},
+		{`[1, {"x": 1}, 3]`, `[1, {"x": 2}, 3, 5]`,
+			`[{"op":"replace","path":"/1/x","value":2},{"op":"add","path":"/3","value":5}]`},
+		{`{"a\"b": 1}`, `{"c\\d": 1}`,
+			`[{"op":"remove","path":"/a\"b"},{"op":"add","path":"/c\\d","value":1}]`},
+	}
+	for _, c := range cases {
+		a, b := mustDecode(t, c.a), mustDecode(t, c.b)
+		patch := CreatePatch(a, b)
+		var buf bytes.Buffer
+		assert.Nil(t, patch.Encode(&buf))
+		assert.Equal(t, c.expected+"\n", buf.String())
+
+		assert.Nil(t, a.ApplyPatch(patch))
+		d, err := CompareDocuments(a, b, nil)
+		assert.Nil(t, err)
+		assert.Len(t, d.Entries, 0, c.b)
+	}
+}
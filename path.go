@@ -0,0 +1,208 @@
+package jsontools
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pathSegment is a step from a container to one of its children: either
+// a member name or an array index.
+type pathSegment struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+func memberSegment(name string) pathSegment {
+	return pathSegment{name: name}
+}
+
+func indexSegment(index int) pathSegment {
+	return pathSegment{index: index, isIndex: true}
+}
+
+// matches reports whether s, used as a pattern, matches t. A member
+// pattern "*" matches any segment, and a member pattern consisting of
+// digits also matches the array index it spells.
+func (s pathSegment) matches(t pathSegment) bool {
+	if !s.isIndex && s.name == "*" {
+		return true
+	}
+	if s.isIndex != t.isIndex {
+		return !s.isIndex && s.name == strconv.Itoa(t.index)
+	}
+	if s.isIndex {
+		return s.index == t.index
+	}
+	return s.name == t.name
+}
+
+// valuePath locates a value within a document.
+type valuePath []pathSegment
+
+func (p valuePath) child(s pathSegment) valuePath {
+	path := make(valuePath, len(p), len(p)+1)
+	copy(path, p)
+	return append(path, s)
+}
+
+// String returns the path in the notation used by the inspector, like
+// ".items[0].name".
+func (p valuePath) String() string {
+	var buf bytes.Buffer
+	for _, s := range p {
+		if s.isIndex {
+			fmt.Fprintf(&buf, "[%d]", s.index)
+		} else {
+			buf.WriteString(".")
+			buf.WriteString(s.name)
+		}
+	}
+	return buf.String()
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// Pointer returns the path as a JSON Pointer (RFC 6901). Member names,
+// which are kept as they appear in the source, have their escape
+// sequences resolved.
+func (p valuePath) Pointer() string {
+	var buf bytes.Buffer
+	for _, s := range p {
+		buf.WriteString("/")
+		if s.isIndex {
+			buf.WriteString(strconv.Itoa(s.index))
+		} else {
+			buf.WriteString(pointerEscaper.Replace(unquoteRaw(s.name)))
+		}
+	}
+	return buf.String()
+}
+
+// simpleMemberName matches member names which JSONPath and jq accept
+// after a dot.
+var simpleMemberName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// JSONPath returns the path as a JSONPath expression, like
+// "$.items[0].sku".
+func (p valuePath) JSONPath() string {
+	var buf bytes.Buffer
+	buf.WriteString("$")
+	for _, s := range p {
+		switch {
+		case s.isIndex:
+			fmt.Fprintf(&buf, "[%d]", s.index)
+		case simpleMemberName.MatchString(s.name):
+			buf.WriteString(".")
+			buf.WriteString(s.name)
+		default:
+			fmt.Fprintf(&buf, "[\"%s\"]", s.name)
+		}
+	}
+	return buf.String()
+}
+
+// Jq returns the path as a jq path, like ".items[0].sku".
+func (p valuePath) Jq() string {
+	var buf bytes.Buffer
+	for _, s := range p {
+		if !s.isIndex && simpleMemberName.MatchString(s.name) {
+			buf.WriteString(".")
+			buf.WriteString(s.name)
+			continue
+		}
+		if buf.Len() == 0 {
+			buf.WriteString(".")
+		}
+		if s.isIndex {
+			fmt.Fprintf(&buf, "[%d]", s.index)
+		} else {
+			fmt.Fprintf(&buf, "[\"%s\"]", s.name)
+		}
+	}
+	if buf.Len() == 0 {
+		return "."
+	}
+	return buf.String()
+}
+
+// hasPrefix reports whether pattern matches p or one of its ancestors.
+func (p valuePath) hasPrefix(pattern valuePath) bool {
+	if len(pattern) > len(p) {
+		return false
+	}
+	for i, s := range pattern {
+		if !s.matches(p[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePointer parses a JSON Pointer. All reference tokens are returned
+// as member segments since a pointer alone can't tell them apart from
+// array indices.
+func parsePointer(s string) (valuePath, error) {
+	if s == "" {
+		return valuePath{}, nil
+	}
+	if s[0] != '/' {
+		return nil, fmt.Errorf("JSON pointer must start with '/': %s", s)
+	}
+	var path valuePath
+	for _, token := range strings.Split(s[1:], "/") {
+		path = append(path, memberSegment(pointerUnescaper.Replace(token)))
+	}
+	return path, nil
+}
+
+// parsePathPattern parses either a JSON Pointer or the inspector notation
+// (".a.b[0]"). "*" and "[*]" match any member or element.
+func parsePathPattern(s string) (valuePath, error) {
+	if s == "" || s == "." {
+		return valuePath{}, nil
+	}
+	if s[0] == '/' {
+		return parsePointer(s)
+	}
+	var path valuePath
+	rest := s
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty member name in %s", s)
+			}
+			path = append(path, memberSegment(rest[:end]))
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']' in %s", s)
+			}
+			token := rest[1:end]
+			if token == "*" {
+				path = append(path, memberSegment("*"))
+			} else {
+				index, err := strconv.Atoi(token)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %q in %s", token, s)
+				}
+				path = append(path, indexSegment(index))
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("expected '.' or '[' in %s", s)
+		}
+	}
+	return path, nil
+}
@@ -0,0 +1,591 @@
+package jsontools
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Queries select index entries. A query is made of terms combined with
+// AND (which may be omitted), OR and NOT, and grouped with parentheses.
+// A term matches identifiers in one of these ways:
+//
+//	foo       contains foo
+//	"foo"     is exactly foo
+//	/f.*o/    matches the regular expression; /f.*o/i ignores case
+//	~foo      contains foo, ignoring case; also ~"foo"
+//
+// and may be restricted with a prefix:
+//
+//	key:      member names only
+//	value:    strings, numbers and literals only
+//	path:     entries at or below a path, like path:items.*.sku
+//	kind:     entries of a kind, like kind:number
+//
+// Numbers can be compared with value>100, value>=, value<, value<= and
+// value=. With fuzzy matching, plain terms like foo ignore case and also
+// match identifiers within a small edit distance.
+
+type queryError struct {
+	pos int
+	msg string
+}
+
+func (e *queryError) Error() string {
+	return fmt.Sprintf("query: column %d: %s", e.pos+1, e.msg)
+}
+
+type matchMode int
+
+const (
+	matchSubstring matchMode = iota
+	matchExact
+	matchRegexp
+)
+
+// matchQuality ranks how well an identifier matches a query.
+type matchQuality int
+
+const (
+	// qualityNone is for entries matched other than by their text.
+	qualityNone matchQuality = iota
+	qualityFuzzy
+	qualitySubstring
+	qualityPrefix
+	qualityExact
+)
+
+// textMatcher matches identifiers against a single term.
+type textMatcher struct {
+	mode matchMode
+	s    string
+	re   *regexp.Regexp
+	fold bool
+	// fuzzy allows substring terms to match with a few typos.
+	fuzzy bool
+}
+
+func (m *textMatcher) match(ident string) bool {
+	return m.quality(ident) != qualityNone
+}
+
+// quality returns how well ident matches, or qualityNone if it doesn't.
+func (m *textMatcher) quality(ident string) matchQuality {
+	switch m.mode {
+	case matchExact:
+		if ident == m.s || m.fold && strings.EqualFold(ident, m.s) {
+			return qualityExact
+		}
+		return qualityNone
+	case matchRegexp:
+		loc := m.re.FindStringIndex(ident)
+		switch {
+		case loc == nil:
+			return qualityNone
+		case loc[0] == 0 && loc[1] == len(ident):
+			return qualityExact
+		case loc[0] == 0:
+			return qualityPrefix
+		}
+		return qualitySubstring
+	}
+	if m.fold || m.fuzzy {
+		ident = strings.ToLower(ident)
+	}
+	switch {
+	case ident == m.s:
+		return qualityExact
+	case strings.HasPrefix(ident, m.s):
+		return qualityPrefix
+	case strings.Contains(ident, m.s):
+		return qualitySubstring
+	case m.fuzzy && withinDistance(ident, m.s, maxTypos(m.s)):
+		return qualityFuzzy
+	}
+	return qualityNone
+}
+
+// maxTypos returns the edit distance fuzzy matching tolerates for s.
+func maxTypos(s string) int {
+	switch n := len([]rune(s)); {
+	case n < 3:
+		return 0
+	case n < 6:
+		return 1
+	}
+	return 2
+}
+
+// withinDistance reports whether the Levenshtein distance between a and
+// b is at most max.
+func withinDistance(a, b string, max int) bool {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra)-len(rb) > max || len(rb)-len(ra) > max {
+		return false
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			best = min(best, cur[j])
+		}
+		if best > max {
+			return false
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)] <= max
+}
+
+// queryEntry is what query nodes are evaluated against.
+type queryEntry struct {
+	ident string
+	kind  IndexKind
+	// path returns the location of the entry, including the member name
+	// for member entries. It is only built when needed.
+	path func() valuePath
+}
+
+type queryNode interface {
+	// mayMatch reports whether an entry with the identifier ident can
+	// match, letting the index skip the entries of other identifiers.
+	mayMatch(ident string) bool
+	eval(e *queryEntry) bool
+	// quality returns how well ident matches the text terms of the node.
+	quality(ident string) matchQuality
+}
+
+// bestQuality returns the best quality of nodes for ident.
+func bestQuality(nodes []queryNode, ident string) matchQuality {
+	best := qualityNone
+	for _, n := range nodes {
+		best = max(best, n.quality(ident))
+	}
+	return best
+}
+
+type andNode struct {
+	nodes []queryNode
+}
+
+func (n *andNode) mayMatch(ident string) bool {
+	for _, c := range n.nodes {
+		if !c.mayMatch(ident) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *andNode) quality(ident string) matchQuality {
+	return bestQuality(n.nodes, ident)
+}
+
+func (n *andNode) eval(e *queryEntry) bool {
+	for _, c := range n.nodes {
+		if !c.eval(e) {
+			return false
+		}
+	}
+	return true
+}
+
+type orNode struct {
+	nodes []queryNode
+}
+
+func (n *orNode) mayMatch(ident string) bool {
+	for _, c := range n.nodes {
+		if c.mayMatch(ident) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *orNode) quality(ident string) matchQuality {
+	return bestQuality(n.nodes, ident)
+}
+
+func (n *orNode) eval(e *queryEntry) bool {
+	for _, c := range n.nodes {
+		if c.eval(e) {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct {
+	node queryNode
+}
+
+func (n *notNode) mayMatch(ident string) bool {
+	return true
+}
+
+func (n *notNode) quality(ident string) matchQuality {
+	return qualityNone
+}
+
+func (n *notNode) eval(e *queryEntry) bool {
+	return !n.node.eval(e)
+}
+
+// textNode matches identifiers, optionally of member names or values
+// only.
+type textNode struct {
+	matcher *textMatcher
+	keys    bool
+	values  bool
+}
+
+func (n *textNode) mayMatch(ident string) bool {
+	return n.matcher.match(ident)
+}
+
+func (n *textNode) quality(ident string) matchQuality {
+	return n.matcher.quality(ident)
+}
+
+func (n *textNode) eval(e *queryEntry) bool {
+	if n.keys && e.kind != IndexMember || n.values && e.kind == IndexMember {
+		return false
+	}
+	return n.matcher.match(e.ident)
+}
+
+type pathNode struct {
+	pattern valuePath
+}
+
+func (n *pathNode) mayMatch(ident string) bool {
+	return true
+}
+
+func (n *pathNode) quality(ident string) matchQuality {
+	return qualityNone
+}
+
+func (n *pathNode) eval(e *queryEntry) bool {
+	return e.path().hasPrefix(n.pattern)
+}
+
+type kindNode struct {
+	kinds []IndexKind
+}
+
+func (n *kindNode) mayMatch(ident string) bool {
+	return true
+}
+
+func (n *kindNode) quality(ident string) matchQuality {
+	return qualityNone
+}
+
+func (n *kindNode) eval(e *queryEntry) bool {
+	return hasKind(n.kinds, e.kind)
+}
+
+type compareNode struct {
+	op    string
+	value float64
+}
+
+func (n *compareNode) mayMatch(ident string) bool {
+	x, err := strconv.ParseFloat(ident, 64)
+	if err != nil {
+		return false
+	}
+	switch n.op {
+	case ">":
+		return x > n.value
+	case ">=":
+		return x >= n.value
+	case "<":
+		return x < n.value
+	case "<=":
+		return x <= n.value
+	}
+	return x == n.value
+}
+
+func (n *compareNode) quality(ident string) matchQuality {
+	return qualityNone
+}
+
+func (n *compareNode) eval(e *queryEntry) bool {
+	return e.kind == IndexNumber && n.mayMatch(e.ident)
+}
+
+// queryParser is a recursive descent parser for queries.
+type queryParser struct {
+	s     string
+	pos   int
+	fuzzy bool
+}
+
+func (p *queryParser) errorf(pos int, format string, args ...interface{}) error {
+	return &queryError{pos: pos, msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *queryParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// peekKeyword reports whether the keyword kw comes next.
+func (p *queryParser) peekKeyword(kw string) bool {
+	p.skipSpace()
+	end := p.pos + len(kw)
+	if !strings.HasPrefix(p.s[p.pos:], kw) {
+		return false
+	}
+	return end == len(p.s) || strings.ContainsRune(" \t()", rune(p.s[end]))
+}
+
+// keyword consumes the keyword kw if it comes next.
+func (p *queryParser) keyword(kw string) bool {
+	if !p.peekKeyword(kw) {
+		return false
+	}
+	p.pos += len(kw)
+	return true
+}
+
+func (p *queryParser) atEnd() bool {
+	p.skipSpace()
+	return p.pos >= len(p.s)
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	var nodes []queryNode
+	for {
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		if !p.keyword("OR") {
+			break
+		}
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return &orNode{nodes}, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	var nodes []queryNode
+	for {
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		if p.keyword("AND") {
+			continue
+		}
+		if p.atEnd() || p.s[p.pos] == ')' || p.peekKeyword("OR") {
+			break
+		}
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return &andNode{nodes}, nil
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	if p.keyword("NOT") {
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{n}, nil
+	}
+	if p.atEnd() {
+		return nil, p.errorf(p.pos, "expected a term")
+	}
+	switch p.s[p.pos] {
+	case '(':
+		start := p.pos
+		p.pos++
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.atEnd() || p.s[p.pos] != ')' {
+			return nil, p.errorf(start, "missing ')'")
+		}
+		p.pos++
+		return n, nil
+	case ')':
+		return nil, p.errorf(p.pos, "unexpected ')'")
+	}
+	return p.parseTerm()
+}
+
+// word consumes characters up to the next space or parenthesis.
+func (p *queryParser) word() string {
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(" \t()", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// delimited consumes text enclosed in delim, which must come next, and
+// returns the text. Escape sequences are kept as they are, since indexed
+// strings are not unescaped either.
+func (p *queryParser) delimited(delim byte) (string, error) {
+	start := p.pos
+	for p.pos++; p.pos < len(p.s); p.pos++ {
+		switch p.s[p.pos] {
+		case '\\':
+			p.pos++
+		case delim:
+			p.pos++
+			return p.s[start+1 : p.pos-1], nil
+		}
+	}
+	return "", p.errorf(start, "unterminated %c", delim)
+}
+
+func (p *queryParser) parseMatcher() (*textMatcher, error) {
+	start := p.pos
+	m := &textMatcher{}
+	if p.pos < len(p.s) && p.s[p.pos] == '~' {
+		m.fold = true
+		p.pos++
+	}
+	if p.pos >= len(p.s) {
+		return nil, p.errorf(start, "expected a term")
+	}
+	switch p.s[p.pos] {
+	case '"':
+		s, err := p.delimited('"')
+		if err != nil {
+			return nil, err
+		}
+		m.mode = matchExact
+		m.s = s
+	case '/':
+		if m.fold {
+			return nil, p.errorf(start, "use /.../i to ignore case in a regular expression")
+		}
+		s, err := p.delimited('/')
+		if err != nil {
+			return nil, err
+		}
+		if flags := p.word(); flags == "i" {
+			s = "(?i)" + s
+		} else if flags != "" {
+			return nil, p.errorf(start, "unknown regular expression flags %q", flags)
+		}
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, p.errorf(start, "%s", err)
+		}
+		m.mode = matchRegexp
+		m.re = re
+	default:
+		m.s = p.word()
+		if m.s == "" {
+			return nil, p.errorf(start, "expected a term")
+		}
+		m.fuzzy = p.fuzzy
+		if m.fold || m.fuzzy {
+			m.s = strings.ToLower(m.s)
+		}
+	}
+	if p.pos < len(p.s) && !strings.ContainsRune(" \t()", rune(p.s[p.pos])) {
+		return nil, p.errorf(p.pos, "unexpected %q", p.s[p.pos])
+	}
+	return m, nil
+}
+
+var queryComparison = regexp.MustCompile(`^value(>=|<=|>|<|=)`)
+
+var queryField = regexp.MustCompile(`^([a-z]+):`)
+
+func (p *queryParser) parseTerm() (queryNode, error) {
+	start := p.pos
+	rest := p.s[p.pos:]
+	if op := queryComparison.FindString(rest); op != "" {
+		p.pos += len(op)
+		op = op[len("value"):]
+		s := p.word()
+		value, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, p.errorf(start, "value%s needs a number, but got %q", op, s)
+		}
+		return &compareNode{op: op, value: value}, nil
+	}
+	field := ""
+	if m := queryField.FindStringSubmatch(rest); m != nil {
+		field = m[1]
+		p.pos += len(m[0])
+	}
+	switch field {
+	case "":
+		m, err := p.parseMatcher()
+		if err != nil {
+			return nil, err
+		}
+		return &textNode{matcher: m}, nil
+	case "key", "value":
+		m, err := p.parseMatcher()
+		if err != nil {
+			return nil, err
+		}
+		return &textNode{matcher: m, keys: field == "key", values: field == "value"}, nil
+	case "path":
+		s := p.word()
+		if s != "" && !strings.ContainsRune("./[", rune(s[0])) {
+			s = "." + s
+		}
+		pattern, err := parsePathPattern(s)
+		if err != nil {
+			return nil, p.errorf(start, "%s", err)
+		}
+		return &pathNode{pattern}, nil
+	case "kind":
+		kinds, err := ParseIndexKinds(p.word())
+		if err != nil {
+			return nil, p.errorf(start, "%s", err)
+		}
+		return &kindNode{kinds}, nil
+	}
+	return nil, p.errorf(start, "unknown field %q", field)
+}
+
+// parseQuery parses a query in the language described above, with fuzzy
+// matching of plain terms if fuzzy is set.
+func parseQuery(s string, fuzzy bool) (queryNode, error) {
+	p := &queryParser{s: s, fuzzy: fuzzy}
+	if p.atEnd() {
+		return nil, p.errorf(0, "empty query")
+	}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.atEnd() {
+		return nil, p.errorf(p.pos, "unexpected %q", p.s[p.pos])
+	}
+	return n, nil
+}
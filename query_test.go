@@ -0,0 +1,105 @@
+package jsontools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	index, err := NewIndexer(strings.NewReader(`{
+  "order": {"id": 1042, "status": "Shipped", "note": "ship \"fast\""},
+  "items": [
+    {"sku": "A-1", "qty": 150, "status": "backorder"},
+    {"sku": "B-2", "qty": 20}
+  ],
+  "shipped": true
+}`)).CreateIndex()
+	assert.Nil(t, err)
+
+	for q, expected := range map[string][]string{
+		`ship`:                              {`ship \"fast\"`, "shipped"},
+		`~ship`:                             {"Shipped", `ship \"fast\"`, "shipped"},
+		`"shipped"`:                         {"shipped"},
+		`~"SHIPPED"`:                        {"Shipped", "shipped"},
+		`"ship \"fast\""`:                   {`ship \"fast\"`},
+		`/^[AB]-\d$/`:                       {"A-1", "B-2"},
+		`/^status$/i`:                       {"status", "status"},
+		`key:status`:                        {"status", "status"},
+		`value:ship`:                        {`ship \"fast\"`},
+		`key:ship`:                          {"shipped"},
+		`path:items.*.sku`:                  {"sku", "A-1", "sku", "B-2"},
+		`path:items.1 kind:number`:          {"20"},
+		`value>100`:                         {"1042", "150"},
+		`value>=20 AND value<=150`:          {"150", "20"},
+		`value=20 OR "A-1"`:                 {"A-1", "20"},
+		`path:items AND NOT key:/./`:        {"A-1", "150", "backorder", "B-2", "20"},
+		`(key:sku OR key:qty) path:items.0`: {"sku", "qty"},
+		`kind:literal`:                      {"true"},
+		`NOT (kind:member OR kind:string)`:  {"1042", "150", "20", "true"},
+	} {
+		entries, err := index.Match(q)
+		if assert.Nil(t, err, q) {
+			assert.Equal(t, expected, entryIdents(entries), q)
+		}
+	}
+
+	for q, msg := range map[string]string{
+		``:              "query: column 1: empty query",
+		`a AND`:         "query: column 6: expected a term",
+		`(a OR b`:       "query: column 1: missing ')'",
+		`a)`:            "query: column 2: unexpected ')'",
+		`"abc`:          "query: column 1: unterminated \"",
+		`/a(/`:          "query: column 1: error parsing regexp: missing closing ): `a(`",
+		`/a/x`:          "query: column 1: unknown regular expression flags \"x\"",
+		`color:red`:     "query: column 1: unknown field \"color\"",
+		`value>ten`:     "query: column 1: value> needs a number, but got \"ten\"",
+		`kind:thing`:    "query: column 1: unknown kind: thing",
+		`path:items[x]`: "query: column 1: invalid index \"x\" in .items[x]",
+		`"a"b`:          "query: column 4: unexpected 'b'",
+	} {
+		_, err := index.Match(q)
+		if assert.NotNil(t, err, q) {
+			assert.Equal(t, msg, err.Error(), q)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	index, err := NewIndexer(strings.NewReader(
+		`[{"userid": 1, "id": 2, "identity": "x", "uid": "id"}, {"idx": 3, "ID": 4}]`)).CreateIndex()
+	assert.Nil(t, err)
+
+	result, err := index.Search("id", &SearchOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 6, result.Total)
+	assert.Equal(t, []string{"id", "id", "identity", "idx", "userid", "uid"}, entryIdents(result.Entries))
+
+	result, err = index.Search("id", &SearchOptions{Kinds: []IndexKind{IndexMember}, Offset: 1, Limit: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, result.Total)
+	assert.Equal(t, []string{"identity", "idx"}, entryIdents(result.Entries))
+
+	result, err = index.Search("id", &SearchOptions{Offset: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(result.Entries))
+
+	result, err = index.Search("identiy OR usrid", &SearchOptions{Fuzzy: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"userid", "identity"}, entryIdents(result.Entries))
+	result, err = index.Search("identiy", &SearchOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, result.Total)
+
+	result, err = index.Search("id", &SearchOptions{Fuzzy: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "id", "ID", "identity", "idx", "userid", "uid"}, entryIdents(result.Entries))
+}
+
+func TestWithinDistance(t *testing.T) {
+	assert.True(t, withinDistance("kitten", "sitting", 3))
+	assert.False(t, withinDistance("kitten", "sitting", 2))
+	assert.True(t, withinDistance("", "ab", 2))
+	assert.True(t, withinDistance("héllo", "hello", 1))
+}
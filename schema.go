@@ -0,0 +1,756 @@
+package jsontools
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError is a violation of a JSON Schema.
+type ValidationError struct {
+	// InstancePath is a JSON Pointer to the offending value.
+	InstancePath string
+	// SchemaPath is a JSON Pointer to the failing keyword.
+	SchemaPath string
+	// Pos is where the offending value starts in the source. It is zero
+	// unless the document was decoded by DecodeWithPositions.
+	Pos     ParserPosition
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	path := e.InstancePath
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", e.Pos.String(), path, e.Message, e.SchemaPath)
+}
+
+func (e *ValidationError) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(
+		`{"instancePath":%s,"schemaPath":%s,"line":%d,"column":%d,"message":%s}`,
+		quoteString(e.InstancePath), quoteString(e.SchemaPath),
+		e.Pos.Line, e.Pos.Column, quoteString(e.Message))), nil
+}
+
+// Schema is a JSON Schema (draft 2020-12).
+type Schema struct {
+	doc     *decodeResult
+	anchors map[string]jsonValue
+	// dynamicAnchors maps each schema resource, which is the root or a
+	// subschema with "$id", to the "$dynamicAnchor"s declared in it.
+	dynamicAnchors map[*objectValue]map[string]jsonValue
+	regexps        map[string]*regexp.Regexp
+}
+
+func NewSchema(r io.Reader) (*Schema, error) {
+	doc, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := doc.toplevel.(*objectValue); !ok {
+		return nil, fmt.Errorf("schema must be an object")
+	}
+	s := &Schema{
+		doc:            doc,
+		anchors:        make(map[string]jsonValue),
+		dynamicAnchors: make(map[*objectValue]map[string]jsonValue),
+		regexps:        make(map[string]*regexp.Regexp),
+	}
+	s.collectAnchors(doc.toplevel, doc.toplevel.(*objectValue))
+	return s, nil
+}
+
+func (s *Schema) keyword(obj *objectValue, name string) jsonValue {
+	return patchMember(s.doc, obj, name)
+}
+
+func (s *Schema) stringKeyword(obj *objectValue, name string) (string, bool) {
+	v, ok := s.keyword(obj, name).(*stringValue)
+	if !ok {
+		return "", false
+	}
+	return s.doc.text(v.id), true
+}
+
+// isResource reports whether obj starts a schema resource.
+func (s *Schema) isResource(obj *objectValue) bool {
+	_, ok := s.stringKeyword(obj, "$id")
+	return ok || obj == s.doc.toplevel
+}
+
+func (s *Schema) collectAnchors(v jsonValue, resource *objectValue) {
+	switch value := v.(type) {
+	case *objectValue:
+		if s.isResource(value) {
+			resource = value
+		}
+		for _, kw := range []string{"$anchor", "$dynamicAnchor"} {
+			if name, ok := s.stringKeyword(value, kw); ok {
+				s.anchors[name] = value
+			}
+		}
+		if name, ok := s.stringKeyword(value, "$dynamicAnchor"); ok {
+			if s.dynamicAnchors[resource] == nil {
+				s.dynamicAnchors[resource] = make(map[string]jsonValue)
+			}
+			s.dynamicAnchors[resource][name] = value
+		}
+		for _, p := range value.props {
+			s.collectAnchors(p, resource)
+		}
+	case *arrayValue:
+		for _, e := range value.elems {
+			s.collectAnchors(e, resource)
+		}
+	}
+}
+
+func (s *Schema) regexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := s.regexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(unquoteRaw(pattern))
+	if err != nil {
+		return nil, err
+	}
+	s.regexps[pattern] = re
+	return re, nil
+}
+
+// resolve returns the subschema a "$ref" refers to. Only references
+// within the schema document are supported.
+func (s *Schema) resolve(ref string) (jsonValue, error) {
+	hash := strings.IndexByte(ref, '#')
+	if hash < 0 {
+		hash = len(ref)
+	}
+	if base := ref[:hash]; base != "" {
+		id, _ := s.stringKeyword(s.doc.toplevel.(*objectValue), "$id")
+		if base != id {
+			return nil, fmt.Errorf("unsupported external reference %q", ref)
+		}
+	}
+	fragment := ""
+	if hash < len(ref) {
+		fragment = ref[hash+1:]
+	}
+	fragment, err := url.PathUnescape(fragment)
+	if err != nil {
+		return nil, err
+	}
+	if fragment == "" {
+		return s.doc.toplevel, nil
+	}
+	if fragment[0] != '/' {
+		if v, ok := s.anchors[fragment]; ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("unknown anchor %q", fragment)
+	}
+	path, err := parsePointer(fragment)
+	if err != nil {
+		return nil, err
+	}
+	v := s.doc.toplevel
+	for _, seg := range path {
+		switch cur := v.(type) {
+		case *objectValue:
+			v = s.keyword(cur, seg.name)
+		case *arrayValue:
+			index, err := strconv.Atoi(seg.name)
+			if err != nil || index < 0 || index >= len(cur.elems) {
+				return nil, fmt.Errorf("invalid reference %q", ref)
+			}
+			v = cur.elems[index]
+		default:
+			v = nil
+		}
+		if v == nil {
+			return nil, fmt.Errorf("invalid reference %q", ref)
+		}
+	}
+	return v, nil
+}
+
+// Validate checks doc against the schema and returns every violation.
+func (s *Schema) Validate(doc *decodeResult) []*ValidationError {
+	v := &schemaValidator{
+		schema: s,
+		doc:    doc,
+	}
+	return v.validate(s.doc.toplevel, valuePath{}, doc.toplevel, valuePath{}).errors
+}
+
+// Subschemas nested deeper than this through "$ref" are assumed to loop.
+const maxSchemaDepth = 256
+
+type schemaValidator struct {
+	schema *Schema
+	doc    *decodeResult
+	depth  int
+	// scope holds the schema resources entered so far, outermost first,
+	// for "$dynamicRef".
+	scope []*objectValue
+}
+
+// schemaResult holds the errors found by validating an instance against
+// a subschema, and which members and elements the subschema evaluated.
+type schemaResult struct {
+	errors []*ValidationError
+	// props holds the evaluated member names.
+	props map[string]bool
+	// items is the number of leading elements evaluated.
+	items    int
+	allItems bool
+	// containsItems holds the indices of elements matching "contains".
+	containsItems map[int]bool
+}
+
+func newSchemaResult() *schemaResult {
+	return &schemaResult{
+		props:         make(map[string]bool),
+		containsItems: make(map[int]bool),
+	}
+}
+
+func (r *schemaResult) valid() bool {
+	return len(r.errors) == 0
+}
+
+// merge adds the annotations of a successfully validated subschema.
+func (r *schemaResult) merge(sub *schemaResult) {
+	for name := range sub.props {
+		r.props[name] = true
+	}
+	if sub.items > r.items {
+		r.items = sub.items
+	}
+	r.allItems = r.allItems || sub.allItems
+	for i := range sub.containsItems {
+		r.containsItems[i] = true
+	}
+}
+
+func (v *schemaValidator) fail(res *schemaResult, sPath valuePath, inst jsonValue, iPath valuePath, format string, args ...interface{}) {
+	res.errors = append(res.errors, &ValidationError{
+		InstancePath: iPath.Pointer(),
+		SchemaPath:   sPath.Pointer(),
+		Pos:          v.positionOf(inst),
+		Message:      fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *schemaValidator) typeOf(inst jsonValue) string {
+	switch value := inst.(type) {
+	case *objectValue:
+		return "object"
+	case *arrayValue:
+		return "array"
+	case *stringValue:
+		return "string"
+	case *numberValue:
+		if value.value == math.Trunc(value.value) {
+			return "integer"
+		}
+		return "number"
+	case *literalValue:
+		if value.value == Null {
+			return "null"
+		}
+		return "boolean"
+	}
+	return "unknown"
+}
+
+func (v *schemaValidator) hasType(inst jsonValue, t string) bool {
+	actual := v.typeOf(inst)
+	return actual == t || (t == "number" && actual == "integer")
+}
+
+// equal compares an instance value with a value in the schema.
+func (v *schemaValidator) equal(inst, value jsonValue) bool {
+	d := &differ{a: v.doc, b: v.schema.doc}
+	return d.equal(valuePath{}, inst, value)
+}
+
+func (v *schemaValidator) describe(inst jsonValue) string {
+	return truncateText(v.doc.encodeValue(inst), 40)
+}
+
+func schemaNumber(s jsonValue) (float64, bool) {
+	n, ok := s.(*numberValue)
+	if !ok {
+		return 0, false
+	}
+	return n.value, true
+}
+
+// schemaNode is a schema object and its location in the schema document.
+type schemaNode struct {
+	schema *Schema
+	obj    *objectValue
+	path   valuePath
+}
+
+func (n *schemaNode) kw(name string) jsonValue {
+	return n.schema.keyword(n.obj, name)
+}
+
+// at returns the location of the keyword name.
+func (n *schemaNode) at(name string) valuePath {
+	return n.path.child(memberSegment(name))
+}
+
+func (v *schemaValidator) validate(s jsonValue, sPath valuePath, inst jsonValue, iPath valuePath) *schemaResult {
+	res := newSchemaResult()
+	switch schema := s.(type) {
+	case *literalValue:
+		if schema.value == False {
+			v.fail(res, sPath, inst, iPath, "no value is allowed here")
+		}
+		return res
+	case *objectValue:
+		if v.depth >= maxSchemaDepth {
+			v.fail(res, sPath, inst, iPath, "schema references nest too deeply")
+			return res
+		}
+		v.depth++
+		v.validateObject(res, schema, sPath, inst, iPath)
+		v.depth--
+	}
+	return res
+}
+
+func (v *schemaValidator) validateObject(res *schemaResult, schema *objectValue, sPath valuePath, inst jsonValue, iPath valuePath) {
+	n := &schemaNode{
+		schema: v.schema,
+		obj:    schema,
+		path:   sPath,
+	}
+
+	if v.schema.isResource(schema) {
+		v.scope = append(v.scope, schema)
+		defer func() { v.scope = v.scope[:len(v.scope)-1] }()
+	}
+
+	for _, name := range []string{"$ref", "$dynamicRef"} {
+		ref, ok := n.kw(name).(*stringValue)
+		if !ok {
+			continue
+		}
+		var target jsonValue
+		var err error
+		if name == "$ref" {
+			target, err = v.schema.resolve(v.schema.doc.text(ref.id))
+		} else {
+			target, err = v.resolveDynamic(v.schema.doc.text(ref.id))
+		}
+		if err != nil {
+			v.fail(res, n.at(name), inst, iPath, "%v", err)
+			continue
+		}
+		sub := v.validate(target, n.at(name), inst, iPath)
+		res.errors = append(res.errors, sub.errors...)
+		if sub.valid() {
+			res.merge(sub)
+		}
+	}
+
+	v.validateType(res, n, inst, iPath)
+	v.validateApplicators(res, n, inst, iPath)
+
+	switch value := inst.(type) {
+	case *numberValue:
+		v.validateNumber(res, n, value, iPath)
+	case *stringValue:
+		v.validateString(res, n, value, iPath)
+	case *arrayValue:
+		v.validateArray(res, n, value, iPath)
+	case *objectValue:
+		v.validateProperties(res, n, value, iPath)
+	}
+}
+
+// resolveDynamic returns the subschema a "$dynamicRef" refers to. A
+// reference to a "$dynamicAnchor" resolves to the anchor of that name in
+// the outermost resource of the dynamic scope which declares one; any
+// other reference behaves like "$ref".
+func (v *schemaValidator) resolveDynamic(ref string) (jsonValue, error) {
+	target, err := v.schema.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	hash := strings.IndexByte(ref, '#')
+	if hash < 0 {
+		return target, nil
+	}
+	name := ref[hash+1:]
+	obj, ok := target.(*objectValue)
+	if !ok {
+		return target, nil
+	}
+	if anchor, ok := v.schema.stringKeyword(obj, "$dynamicAnchor"); !ok || anchor != name {
+		return target, nil
+	}
+	for _, resource := range v.scope {
+		if t, ok := v.schema.dynamicAnchors[resource][name]; ok {
+			return t, nil
+		}
+	}
+	return target, nil
+}
+
+func (v *schemaValidator) validateType(res *schemaResult, n *schemaNode, inst jsonValue, iPath valuePath) {
+	switch t := n.kw("type").(type) {
+	case *stringValue:
+		name := v.schema.doc.text(t.id)
+		if !v.hasType(inst, name) {
+			v.fail(res, n.at("type"), inst, iPath, "expected %s, but got %s", name, v.typeOf(inst))
+		}
+	case *arrayValue:
+		var names []string
+		for _, e := range t.elems {
+			if name, ok := e.(*stringValue); ok {
+				names = append(names, v.schema.doc.text(name.id))
+				if v.hasType(inst, v.schema.doc.text(name.id)) {
+					return
+				}
+			}
+		}
+		v.fail(res, n.at("type"), inst, iPath, "expected %s, but got %s",
+			strings.Join(names, " or "), v.typeOf(inst))
+	}
+
+	if c := n.kw("const"); c != nil && !v.equal(inst, c) {
+		v.fail(res, n.at("const"), inst, iPath, "expected %s, but got %s",
+			v.schema.doc.encodeValue(c), v.describe(inst))
+	}
+	if enum, ok := n.kw("enum").(*arrayValue); ok {
+		found := false
+		for _, e := range enum.elems {
+			if v.equal(inst, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(res, n.at("enum"), inst, iPath, "%s is not one of the allowed values", v.describe(inst))
+		}
+	}
+}
+
+func (v *schemaValidator) validateApplicators(res *schemaResult, n *schemaNode, inst jsonValue, iPath valuePath) {
+	if allOf, ok := n.kw("allOf").(*arrayValue); ok {
+		for i, s := range allOf.elems {
+			sub := v.validate(s, n.at("allOf").child(indexSegment(i)), inst, iPath)
+			res.errors = append(res.errors, sub.errors...)
+			if sub.valid() {
+				res.merge(sub)
+			}
+		}
+	}
+	if anyOf, ok := n.kw("anyOf").(*arrayValue); ok {
+		matched := false
+		for i, s := range anyOf.elems {
+			sub := v.validate(s, n.at("anyOf").child(indexSegment(i)), inst, iPath)
+			if sub.valid() {
+				matched = true
+				res.merge(sub)
+			}
+		}
+		if !matched {
+			v.fail(res, n.at("anyOf"), inst, iPath, "does not match any of the schemas")
+		}
+	}
+	if oneOf, ok := n.kw("oneOf").(*arrayValue); ok {
+		var matches []int
+		for i, s := range oneOf.elems {
+			sub := v.validate(s, n.at("oneOf").child(indexSegment(i)), inst, iPath)
+			if sub.valid() {
+				matches = append(matches, i)
+				res.merge(sub)
+			}
+		}
+		if len(matches) == 0 {
+			v.fail(res, n.at("oneOf"), inst, iPath, "does not match any of the schemas")
+		} else if len(matches) > 1 {
+			v.fail(res, n.at("oneOf"), inst, iPath, "matches schemas %v, but must match exactly one", matches)
+		}
+	}
+	if not := n.kw("not"); not != nil {
+		if v.validate(not, n.at("not"), inst, iPath).valid() {
+			v.fail(res, n.at("not"), inst, iPath, "must not match the schema")
+		}
+	}
+	if cond := n.kw("if"); cond != nil {
+		sub := v.validate(cond, n.at("if"), inst, iPath)
+		branch := "else"
+		if sub.valid() {
+			res.merge(sub)
+			branch = "then"
+		}
+		if s := n.kw(branch); s != nil {
+			sub := v.validate(s, n.at(branch), inst, iPath)
+			res.errors = append(res.errors, sub.errors...)
+			if sub.valid() {
+				res.merge(sub)
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validateNumber(res *schemaResult, n *schemaNode, inst *numberValue, iPath valuePath) {
+	x := inst.value
+	if m, ok := schemaNumber(n.kw("multipleOf")); ok && m > 0 {
+		q := x / m
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			v.fail(res, n.at("multipleOf"), inst, iPath, "%s is not a multiple of %s", formatNumber(x), formatNumber(m))
+		}
+	}
+	if max, ok := schemaNumber(n.kw("maximum")); ok && x > max {
+		v.fail(res, n.at("maximum"), inst, iPath, "%s is greater than %s", formatNumber(x), formatNumber(max))
+	}
+	if max, ok := schemaNumber(n.kw("exclusiveMaximum")); ok && x >= max {
+		v.fail(res, n.at("exclusiveMaximum"), inst, iPath, "%s is not less than %s", formatNumber(x), formatNumber(max))
+	}
+	if min, ok := schemaNumber(n.kw("minimum")); ok && x < min {
+		v.fail(res, n.at("minimum"), inst, iPath, "%s is less than %s", formatNumber(x), formatNumber(min))
+	}
+	if min, ok := schemaNumber(n.kw("exclusiveMinimum")); ok && x <= min {
+		v.fail(res, n.at("exclusiveMinimum"), inst, iPath, "%s is not greater than %s", formatNumber(x), formatNumber(min))
+	}
+}
+
+func (v *schemaValidator) validateString(res *schemaResult, n *schemaNode, inst *stringValue, iPath valuePath) {
+	s := unquoteRaw(v.doc.str(inst.id))
+	length := utf8.RuneCountInString(s)
+	if max, ok := schemaNumber(n.kw("maxLength")); ok && float64(length) > max {
+		v.fail(res, n.at("maxLength"), inst, iPath, "length %d is greater than %s", length, formatNumber(max))
+	}
+	if min, ok := schemaNumber(n.kw("minLength")); ok && float64(length) < min {
+		v.fail(res, n.at("minLength"), inst, iPath, "length %d is less than %s", length, formatNumber(min))
+	}
+	if pattern, ok := n.kw("pattern").(*stringValue); ok {
+		re, err := v.schema.regexp(v.schema.doc.str(pattern.id))
+		if err != nil {
+			v.fail(res, n.at("pattern"), inst, iPath, "invalid pattern: %v", err)
+		} else if !re.MatchString(s) {
+			v.fail(res, n.at("pattern"), inst, iPath, "%s does not match %s", v.describe(inst), re.String())
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(res *schemaResult, n *schemaNode, inst *arrayValue, iPath valuePath) {
+	count := len(inst.elems)
+	if max, ok := schemaNumber(n.kw("maxItems")); ok && float64(count) > max {
+		v.fail(res, n.at("maxItems"), inst, iPath, "%d items, but at most %s allowed", count, formatNumber(max))
+	}
+	if min, ok := schemaNumber(n.kw("minItems")); ok && float64(count) < min {
+		v.fail(res, n.at("minItems"), inst, iPath, "%d items, but at least %s required", count, formatNumber(min))
+	}
+	if unique, ok := n.kw("uniqueItems").(*literalValue); ok && unique.value == True {
+		d := &differ{a: v.doc, b: v.doc}
+	outer:
+		for i := range inst.elems {
+			for j := 0; j < i; j++ {
+				if d.equal(valuePath{}, inst.elems[i], inst.elems[j]) {
+					v.fail(res, n.at("uniqueItems"), inst, iPath, "items %d and %d are equal", j, i)
+					break outer
+				}
+			}
+		}
+	}
+
+	prefix := 0
+	if prefixItems, ok := n.kw("prefixItems").(*arrayValue); ok {
+		for i, s := range prefixItems.elems {
+			if i >= count {
+				break
+			}
+			sub := v.validate(s, n.at("prefixItems").child(indexSegment(i)), inst.elems[i], iPath.child(indexSegment(i)))
+			res.errors = append(res.errors, sub.errors...)
+		}
+		prefix = len(prefixItems.elems)
+		if prefix > count {
+			prefix = count
+		}
+		if prefix > res.items {
+			res.items = prefix
+		}
+	}
+	if items := n.kw("items"); items != nil {
+		for i := prefix; i < count; i++ {
+			sub := v.validate(items, n.at("items"), inst.elems[i], iPath.child(indexSegment(i)))
+			res.errors = append(res.errors, sub.errors...)
+		}
+		res.allItems = true
+	}
+	if contains := n.kw("contains"); contains != nil {
+		matches := 0
+		for i, e := range inst.elems {
+			if v.validate(contains, n.at("contains"), e, iPath.child(indexSegment(i))).valid() {
+				matches++
+				res.containsItems[i] = true
+			}
+		}
+		min := 1.0
+		if m, ok := schemaNumber(n.kw("minContains")); ok {
+			min = m
+		}
+		if float64(matches) < min {
+			v.fail(res, n.at("contains"), inst, iPath, "%d items match \"contains\", but at least %s required", matches, formatNumber(min))
+		}
+		if max, ok := schemaNumber(n.kw("maxContains")); ok && float64(matches) > max {
+			v.fail(res, n.at("maxContains"), inst, iPath, "%d items match \"contains\", but at most %s allowed", matches, formatNumber(max))
+		}
+	}
+	if unevaluated := n.kw("unevaluatedItems"); unevaluated != nil && !res.allItems {
+		for i := res.items; i < count; i++ {
+			if res.containsItems[i] {
+				continue
+			}
+			sub := v.validate(unevaluated, n.at("unevaluatedItems"), inst.elems[i], iPath.child(indexSegment(i)))
+			res.errors = append(res.errors, sub.errors...)
+		}
+		res.allItems = true
+	}
+}
+
+func (v *schemaValidator) validateProperties(res *schemaResult, n *schemaNode, inst *objectValue, iPath valuePath) {
+	count := len(inst.keys)
+	if max, ok := schemaNumber(n.kw("maxProperties")); ok && float64(count) > max {
+		v.fail(res, n.at("maxProperties"), inst, iPath, "%d members, but at most %s allowed", count, formatNumber(max))
+	}
+	if min, ok := schemaNumber(n.kw("minProperties")); ok && float64(count) < min {
+		v.fail(res, n.at("minProperties"), inst, iPath, "%d members, but at least %s required", count, formatNumber(min))
+	}
+
+	members := membersByName(v.doc, inst)
+	if required, ok := n.kw("required").(*arrayValue); ok {
+		for _, e := range required.elems {
+			if name, ok := e.(*stringValue); ok {
+				if _, ok := members[v.schema.doc.text(name.id)]; !ok {
+					v.fail(res, n.at("required"), inst, iPath, "missing required member %q", v.schema.doc.text(name.id))
+				}
+			}
+		}
+	}
+	if deps, ok := n.kw("dependentRequired").(*objectValue); ok {
+		for _, id := range deps.keys {
+			name := v.schema.doc.str(id)
+			names, ok := deps.props[id].(*arrayValue)
+			if _, present := members[v.schema.doc.text(id)]; !present || !ok {
+				continue
+			}
+			for _, e := range names.elems {
+				if dep, ok := e.(*stringValue); ok {
+					if _, ok := members[v.schema.doc.text(dep.id)]; !ok {
+						v.fail(res, n.at("dependentRequired").child(memberSegment(name)), inst, iPath,
+							"member %q requires %q", v.schema.doc.text(id), v.schema.doc.text(dep.id))
+					}
+				}
+			}
+		}
+	}
+	if deps, ok := n.kw("dependentSchemas").(*objectValue); ok {
+		for _, id := range deps.keys {
+			name := v.schema.doc.str(id)
+			if _, present := members[v.schema.doc.text(id)]; !present {
+				continue
+			}
+			sub := v.validate(deps.props[id], n.at("dependentSchemas").child(memberSegment(name)), inst, iPath)
+			res.errors = append(res.errors, sub.errors...)
+			if sub.valid() {
+				res.merge(sub)
+			}
+		}
+	}
+	if names := n.kw("propertyNames"); names != nil {
+		for _, id := range inst.keys {
+			name := v.doc.str(id)
+			sub := v.validate(names, n.at("propertyNames"), &stringValue{id}, iPath.child(memberSegment(name)))
+			for _, e := range sub.errors {
+				e.Message = fmt.Sprintf("member name %q: %s", name, e.Message)
+				e.Pos = v.positionOf(inst.props[id])
+			}
+			res.errors = append(res.errors, sub.errors...)
+		}
+	}
+
+	properties, _ := n.kw("properties").(*objectValue)
+	patternProperties, _ := n.kw("patternProperties").(*objectValue)
+	additional := n.kw("additionalProperties")
+	unevaluated := n.kw("unevaluatedProperties")
+	for _, id := range inst.keys {
+		name := v.doc.str(id)
+		child := iPath.child(memberSegment(name))
+		value := inst.props[id]
+		matched := false
+		if properties != nil {
+			if s := v.schema.keyword(properties, v.doc.text(id)); s != nil {
+				matched = true
+				sub := v.validate(s, n.at("properties").child(memberSegment(name)), value, child)
+				res.errors = append(res.errors, sub.errors...)
+			}
+		}
+		if patternProperties != nil {
+			for _, pid := range patternProperties.keys {
+				pattern := v.schema.doc.str(pid)
+				re, err := v.schema.regexp(pattern)
+				if err != nil {
+					v.fail(res, n.at("patternProperties").child(memberSegment(pattern)), inst, iPath, "invalid pattern: %v", err)
+					continue
+				}
+				if !re.MatchString(unquoteRaw(name)) {
+					continue
+				}
+				matched = true
+				sub := v.validate(patternProperties.props[pid], n.at("patternProperties").child(memberSegment(pattern)), value, child)
+				res.errors = append(res.errors, sub.errors...)
+			}
+		}
+		if !matched && additional != nil {
+			matched = true
+			sub := v.validate(additional, n.at("additionalProperties"), value, child)
+			if isFalseSchema(additional) {
+				sub.errors[0].Message = fmt.Sprintf("unexpected member %q", name)
+			}
+			res.errors = append(res.errors, sub.errors...)
+		}
+		if matched {
+			res.props[name] = true
+		}
+	}
+	if unevaluated != nil {
+		for _, id := range inst.keys {
+			name := v.doc.str(id)
+			if res.props[name] {
+				continue
+			}
+			sub := v.validate(unevaluated, n.at("unevaluatedProperties"), inst.props[id], iPath.child(memberSegment(name)))
+			if isFalseSchema(unevaluated) {
+				sub.errors[0].Message = fmt.Sprintf("unexpected member %q", name)
+			}
+			res.errors = append(res.errors, sub.errors...)
+			res.props[name] = true
+		}
+	}
+}
+
+func (v *schemaValidator) positionOf(inst jsonValue) ParserPosition {
+	if v.doc.positions == nil {
+		return ParserPosition{}
+	}
+	return v.doc.positions[inst]
+}
+
+func isFalseSchema(s jsonValue) bool {
+	l, ok := s.(*literalValue)
+	return ok && l.value == False
+}
@@ -0,0 +1,135 @@
+package jsontools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validate(t *testing.T, schema string, doc string) []string {
+	s, err := NewSchema(strings.NewReader(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := DecodeWithPositions(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []string
+	for _, e := range s.Validate(r) {
+		errs = append(errs, e.Error())
+	}
+	return errs
+}
+
+func TestValidate(t *testing.T) {
+	schema := `{
+  "type": "object",
+  "required": ["name", "port"],
+  "properties": {
+    "name": {"type": "string", "minLength": 2, "pattern": "^[a-z]+$"},
+    "port": {"type": "integer", "minimum": 1, "maximum": 65535},
+    "tags": {"type": "array", "items": {"enum": ["a", "b"]}, "uniqueItems": true}
+  },
+  "additionalProperties": false
+}`
+	assert.Equal(t, []string(nil), validate(t, schema,
+		`{"name": "web", "port": 80, "tags": ["a", "b"]}`))
+	assert.Equal(t, []string{
+		`1:1: /: missing required member "port" (/required)`,
+		`1:10: /name: length 1 is less than 2 (/properties/name/minLength)`,
+		`1:10: /name: "X" does not match ^[a-z]+$ (/properties/name/pattern)`,
+		`1:23: /tags: items 0 and 2 are equal (/properties/tags/uniqueItems)`,
+		`1:29: /tags/1: "c" is not one of the allowed values (/properties/tags/items/enum)`,
+		`1:49: /extra: unexpected member "extra" (/additionalProperties)`,
+	}, validate(t, schema, `{"name": "X", "tags": ["a", "c", "a"], "extra": 1}`))
+	assert.Equal(t, []string{
+		`2:11: /port: expected integer, but got number (/properties/port/type)`,
+	}, validate(t, schema, "{\"name\": \"db\",\n  \"port\": 1.5}"))
+}
+
+func TestValidateApplicators(t *testing.T) {
+	schema := `{
+  "$defs": {"positive": {"type": "number", "exclusiveMinimum": 0}},
+  "type": "array",
+  "prefixItems": [{"$ref": "#/$defs/positive"}],
+  "items": {
+    "oneOf": [{"type": "string"}, {"type": "integer"}],
+    "not": {"const": "forbidden"}
+  },
+  "contains": {"type": "string"},
+  "maxContains": 2
+}`
+	assert.Equal(t, []string(nil), validate(t, schema, `[1.5, "a", 2]`))
+	assert.Equal(t, []string{
+		`1:2: /0: 0 is not greater than 0 (/prefixItems/0/$ref/exclusiveMinimum)`,
+		`1:5: /1: does not match any of the schemas (/items/oneOf)`,
+		`1:10: /2: must not match the schema (/items/not)`,
+	}, validate(t, schema, `[0, 2.5, "forbidden"]`))
+	assert.Equal(t, []string{
+		`1:1: /: 3 items match "contains", but at most 2 allowed (/maxContains)`,
+	}, validate(t, schema, `[1, "a", "b", "c"]`))
+}
+
+func TestValidateConditionals(t *testing.T) {
+	schema := `{
+  "properties": {"kind": {"type": "string"}},
+  "if": {"properties": {"kind": {"const": "file"}}},
+  "then": {"required": ["path"], "properties": {"path": {"type": "string"}}},
+  "else": {"required": ["url"]},
+  "dependentRequired": {"user": ["password"]},
+  "unevaluatedProperties": false
+}`
+	assert.Equal(t, []string(nil), validate(t, schema, `{"kind": "file", "path": "/tmp"}`))
+	assert.Equal(t, []string{
+		`1:1: /: missing required member "url" (/else/required)`,
+		`1:1: /: member "user" requires "password" (/dependentRequired/user)`,
+		`1:26: /user: unexpected member "user" (/unevaluatedProperties)`,
+	}, validate(t, schema, `{"kind": "http", "user": "me"}`))
+}
+
+func TestValidateDynamicRef(t *testing.T) {
+	tree := `"tree": {
+      "$id": "tree",
+      "$dynamicAnchor": "node",
+      "type": "object",
+      "properties": {"children": {"type": "array", "items": {"$dynamicRef": "#node"}}}
+    }`
+	schema := `{
+  "$dynamicAnchor": "node",
+  "$ref": "#/$defs/tree",
+  "required": ["name"],
+  "$defs": {
+    ` + tree + `
+  }
+}`
+	assert.Equal(t, []string(nil), validate(t, schema, `{"name": "a", "children": [{"name": "b"}]}`))
+	assert.Equal(t, []string{
+		`1:28: /children/0: missing required member "name" (/$ref/properties/children/items/$dynamicRef/required)`,
+	}, validate(t, schema, `{"name": "a", "children": [{"children": []}]}`))
+
+	// Without an outer anchor, the reference stays within the tree.
+	schema = `{"$ref": "#/$defs/tree", "required": ["name"], "$defs": {` + tree + `}}`
+	assert.Equal(t, []string(nil), validate(t, schema, `{"name": "a", "children": [{"children": []}]}`))
+}
+
+func TestValidateEscapedNames(t *testing.T) {
+	schema := `{
+  "\u0074ype": "object",
+  "required": ["n\u0061me", "id"],
+  "properties": {"n\u0061me": {"type": "string", "pattern": "^a"}, "\u0069d": {"$ref": "#/$defs/\u0069d"}},
+  "$defs": {"id": {"type": "integer"}}
+}`
+	assert.Equal(t, []string(nil), validate(t, schema, `{"name": "ab", "id": 1}`))
+	assert.Equal(t, []string{
+		`1:1: /: missing required member "name" (/required)`,
+		`1:8: /id: expected integer, but got string (/properties/id/$ref/type)`,
+	}, validate(t, schema, `{"id": "x"}`))
+	assert.Equal(t, []string{
+		`1:10: /name: "` + strings.Repeat("é", 36) + `... does not match ^a (/properties/name/pattern)`,
+	}, validate(t, schema, `{"name": "`+strings.Repeat("é", 50)+`", "id": 1}`))
+	assert.Equal(t, []string{
+		`1:1: /: expected object, but got array (/type)`,
+	}, validate(t, schema, `[]`))
+}
@@ -0,0 +1,133 @@
+package jsontools
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Searches stop after this many results.
+const maxSearchResults = 1000
+
+// Value previews are cut to this many characters.
+const maxPreviewLength = 60
+
+// searchResult is a match of the find or grep commands.
+type searchResult struct {
+	// segments lead from the root to the match.
+	segments []string
+	path     string
+	preview  string
+}
+
+// walk calls visit for every member and primitive value below v, which
+// is at path, until visit returns false. It returns false if it stopped
+// early, either for visit or because a value couldn't be decoded.
+func (i *Inspector) walk(v jsonValue, path valuePath, visit func(e *queryEntry, v jsonValue) bool) (bool, error) {
+	switch value := v.(type) {
+	case *objectValue:
+		for _, id := range value.keys {
+			name := i.idToStr(id)
+			memberPath := path.child(memberSegment(name))
+			member, err := i.resolve(value.props[id])
+			if err != nil {
+				return false, err
+			}
+			e := &queryEntry{
+				ident: name,
+				kind:  IndexMember,
+				path:  func() valuePath { return memberPath },
+			}
+			if !visit(e, member) {
+				return false, nil
+			}
+			if ok, err := i.walk(member, memberPath, visit); !ok {
+				return false, err
+			}
+		}
+	case *arrayValue:
+		for index, elem := range value.elems {
+			elem, err := i.resolve(elem)
+			if err != nil {
+				return false, err
+			}
+			if ok, err := i.walk(elem, path.child(indexSegment(index)), visit); !ok {
+				return false, err
+			}
+		}
+	case *stringValue:
+		return visit(&queryEntry{ident: i.idToStr(value.id), kind: IndexString, path: func() valuePath { return path }}, v), nil
+	case *numberValue:
+		return visit(&queryEntry{ident: value.jsonText(), kind: IndexNumber, path: func() valuePath { return path }}, v), nil
+	case *literalValue:
+		return visit(&queryEntry{ident: value.value.String(), kind: IndexLiteral, path: func() valuePath { return path }}, v), nil
+	}
+	return true, nil
+}
+
+func (i *Inspector) preview(v jsonValue) string {
+	s := i.valueToString(v)
+	if _, ok := v.(*stringValue); ok {
+		s = `"` + s + `"`
+	}
+	return truncateText(s, maxPreviewLength)
+}
+
+// search lists the member names (if members is set) or the values below
+// the current node which match the query q, numbering them for goto.
+func (i *Inspector) search(q string, members bool) error {
+	query, err := parseQuery(q, false)
+	if err != nil {
+		return err
+	}
+	base := i.segments()
+	i.results = nil
+	truncated := false
+	_, err = i.walk(i.current().value, nil, func(e *queryEntry, v jsonValue) bool {
+		if (e.kind == IndexMember) != members || !query.mayMatch(e.ident) || !query.eval(e) {
+			return true
+		}
+		if len(i.results) == maxSearchResults {
+			truncated = true
+			return false
+		}
+		path := e.path()
+		segments := append([]string{}, base...)
+		for _, s := range path {
+			if s.isIndex {
+				segments = append(segments, strconv.Itoa(s.index))
+			} else {
+				segments = append(segments, s.name)
+			}
+		}
+		i.results = append(i.results, &searchResult{
+			segments: segments,
+			path:     i.current().path + path.String(),
+			preview:  i.preview(v),
+		})
+		return true
+	})
+	if err != nil {
+		i.results = nil
+		return err
+	}
+	for n, r := range i.results {
+		fmt.Fprintf(i.out, "[%d] ", n+1)
+		memberColor.Fprintf(i.out, "%s", r.path)
+		fmt.Fprintf(i.out, ": %s\n", r.preview)
+	}
+	if truncated {
+		metaColor.Fprintf(i.out, "Stopped after %d results\n", maxSearchResults)
+	} else if len(i.results) == 0 {
+		metaColor.Fprintf(i.out, "No matches\n")
+	}
+	return nil
+}
+
+// gotoResult moves to the result numbered s by the last search.
+func (i *Inspector) gotoResult(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > len(i.results) {
+		return fmt.Errorf("no result %s", s)
+	}
+	return i.navigate(i.stack[:1], i.results[n-1].segments)
+}
@@ -0,0 +1,181 @@
+package jsontools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// statsTypes are the types counted by stats, in the order listed.
+var statsTypes = []string{"object", "array", "string", "number", "boolean", "null"}
+
+// treeStats describes the values below a node.
+type treeStats struct {
+	// counts holds the number of values of each type.
+	counts   map[string]int
+	maxDepth int
+	// keys counts the occurrences of each member name.
+	keys map[string]int
+}
+
+// measure returns the length of the compact JSON text of v, which is
+// depth levels below the node being measured, and adds its values to st.
+func (i *Inspector) measure(v jsonValue, depth int, st *treeStats) (int64, error) {
+	v, err := i.resolve(v)
+	if err != nil {
+		return 0, err
+	}
+	st.counts[typeName(v)]++
+	if depth > st.maxDepth {
+		st.maxDepth = depth
+	}
+	switch value := v.(type) {
+	case *objectValue:
+		size := int64(2)
+		for n, id := range value.keys {
+			name := i.idToStr(id)
+			st.keys[name]++
+			// The quoted name, a colon, and a comma before all but the
+			// first member.
+			member, err := i.measure(value.props[id], depth+1, st)
+			if err != nil {
+				return 0, err
+			}
+			size += int64(len(name)) + 3 + member
+			if n > 0 {
+				size++
+			}
+		}
+		return size, nil
+	case *arrayValue:
+		size := int64(2)
+		for n, e := range value.elems {
+			elem, err := i.measure(e, depth+1, st)
+			if err != nil {
+				return 0, err
+			}
+			size += elem
+			if n > 0 {
+				size++
+			}
+		}
+		return size, nil
+	case *stringValue:
+		return int64(len(i.idToStr(value.id))) + 2, nil
+	case *numberValue:
+		return int64(len(value.jsonText())), nil
+	case *literalValue:
+		return int64(len(value.value.String())), nil
+	}
+	return 0, nil
+}
+
+// formatSize returns n bytes in a readable unit, like "1.5 MB".
+func formatSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	size := float64(n) / 1024
+	for _, unit := range []string{"KB", "MB", "GB"} {
+		if size < 1024 {
+			return fmt.Sprintf("%.1f %s", size, unit)
+		}
+		size /= 1024
+	}
+	return fmt.Sprintf("%.1f TB", size)
+}
+
+// childSize is the size of a member or element of the measured node.
+type childSize struct {
+	name string
+	size int64
+}
+
+// statsCommand prints the serialized size of the current value and of its
+// members or elements, largest first, along with the number of values of
+// each type, the depth of the tree and the most frequent member names.
+func (i *Inspector) statsCommand(args string) error {
+	fs := newFlagSet("stats")
+	count := fs.Int("n", 20, "number of members or elements to list, or 0 for all")
+	keyCount := fs.Int("k", 10, "number of member names to list")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	st := &treeStats{
+		counts: make(map[string]int),
+		keys:   make(map[string]int),
+	}
+	var children []childSize
+	total := int64(0)
+	switch value := i.current().value.(type) {
+	case *objectValue:
+		st.counts["object"]++
+		total = 2
+		for n, id := range value.keys {
+			name := i.idToStr(id)
+			st.keys[name]++
+			size, err := i.measure(value.props[id], 1, st)
+			if err != nil {
+				return err
+			}
+			children = append(children, childSize{name, size})
+			total += int64(len(name)) + 3 + size
+			if n > 0 {
+				total++
+			}
+		}
+	case *arrayValue:
+		st.counts["array"]++
+		total = 2
+		for n, e := range value.elems {
+			size, err := i.measure(e, 1, st)
+			if err != nil {
+				return err
+			}
+			children = append(children, childSize{fmt.Sprintf("[%d]", n), size})
+			total += size
+			if n > 0 {
+				total++
+			}
+		}
+	default:
+		size, err := i.measure(value, 0, st)
+		if err != nil {
+			return err
+		}
+		total = size
+	}
+	sort.SliceStable(children, func(a, b int) bool {
+		return children[a].size > children[b].size
+	})
+
+	metaColor.Fprintf(i.out, "%s: %s, depth %d\n", displayLocation(i.current()), formatSize(total), st.maxDepth)
+	var counts []string
+	for _, t := range statsTypes {
+		if st.counts[t] > 0 {
+			counts = append(counts, fmt.Sprintf("%s %d", t, st.counts[t]))
+		}
+	}
+	fmt.Fprintln(i.out, strings.Join(counts, ", "))
+
+	for n, c := range children {
+		if n == *count && *count > 0 {
+			metaColor.Fprintf(i.out, "... %d more\n", len(children)-n)
+			break
+		}
+		share := float64(c.size) * 100 / float64(total)
+		fmt.Fprintf(i.out, "%9s %5.1f%%  ", formatSize(c.size), share)
+		memberColor.Fprintf(i.out, "%s\n", c.name)
+	}
+
+	if len(st.keys) > 0 {
+		fmt.Fprintf(i.out, "Most frequent members:\n")
+		for n, name := range byCount(st.keys) {
+			if n == *keyCount {
+				break
+			}
+			fmt.Fprintf(i.out, "  %s: %d\n", name, st.keys[name])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package jsontools
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// elementColumn holds the elements of an array which aren't objects, as
+// in summary.
+const elementColumn = "(element)"
+
+// tableRow is an element of the array shown by the table command.
+type tableRow struct {
+	index int
+	// cells holds the value of each column, or nil where the element
+	// lacks the member.
+	cells []jsonValue
+}
+
+type tableView struct {
+	columns []string
+	rows    []*tableRow
+}
+
+// truncateText cuts s to at most width runes, ending it with "..." if
+// anything was cut.
+func truncateText(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	if width <= 3 {
+		return "..."[:width]
+	}
+	runes := 0
+	for n := range s {
+		if runes == width-3 {
+			return s[:n] + "..."
+		}
+		runes++
+	}
+	return s
+}
+
+// newTableView returns the elements of arr as rows, with a column for each
+// of columns, or for every member found in the elements if columns is
+// empty.
+func (i *Inspector) newTableView(arr *arrayValue, columns []string) (*tableView, error) {
+	t := &tableView{columns: columns}
+	elems := make([]jsonValue, len(arr.elems))
+	for n, e := range arr.elems {
+		v, err := i.resolve(e)
+		if err != nil {
+			return nil, err
+		}
+		elems[n] = v
+	}
+	found := make(map[string]bool)
+	for _, e := range elems {
+		obj, ok := e.(*objectValue)
+		if !ok {
+			found[elementColumn] = true
+			continue
+		}
+		for _, id := range obj.keys {
+			found[i.idToStr(id)] = true
+		}
+	}
+	if len(columns) == 0 {
+		fields, err := i.summarize(arr)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			t.columns = append(t.columns, f.name)
+		}
+	}
+	for _, column := range columns {
+		if !found[column] {
+			return nil, fmt.Errorf("no column %q", column)
+		}
+	}
+	for n, e := range elems {
+		row := &tableRow{index: n, cells: make([]jsonValue, len(t.columns))}
+		for c, column := range t.columns {
+			switch value := e.(type) {
+			case *objectValue:
+				row.cells[c] = i.findMember(value, column)
+			default:
+				if column == elementColumn {
+					row.cells[c] = value
+				}
+			}
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t, nil
+}
+
+// compareCells orders numbers numerically before anything else, which is
+// ordered by its text, and missing cells last.
+func (i *Inspector) compareCells(a, b jsonValue) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return 1
+		}
+		return -1
+	}
+	na, aNumber := a.(*numberValue)
+	nb, bNumber := b.(*numberValue)
+	switch {
+	case aNumber && bNumber:
+		switch {
+		case na.value < nb.value:
+			return -1
+		case na.value > nb.value:
+			return 1
+		}
+		return 0
+	case aNumber:
+		return -1
+	case bNumber:
+		return 1
+	}
+	sa, sb := i.cellText(a), i.cellText(b)
+	switch {
+	case sa < sb:
+		return -1
+	case sa > sb:
+		return 1
+	}
+	return 0
+}
+
+// sortTable sorts the rows of t by column, keeping the order of equal rows.
+// Missing cells go last either way.
+func (i *Inspector) sortTable(t *tableView, column string, descending bool) error {
+	c := -1
+	for n, name := range t.columns {
+		if name == column {
+			c = n
+		}
+	}
+	if c < 0 {
+		return fmt.Errorf("no column %q to sort by", column)
+	}
+	sort.SliceStable(t.rows, func(a, b int) bool {
+		va, vb := t.rows[a].cells[c], t.rows[b].cells[c]
+		if descending && va != nil && vb != nil {
+			return i.compareCells(vb, va) < 0
+		}
+		return i.compareCells(va, vb) < 0
+	})
+	return nil
+}
+
+// cellText returns the text of a cell as it appears in the source, so
+// that escape sequences keep it on one line.
+func (i *Inspector) cellText(v jsonValue) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case *stringValue:
+		return i.idToStr(value.id)
+	case *numberValue:
+		return formatNumber(value.value)
+	case *literalValue:
+		return value.value.String()
+	}
+	return typeLabel(v)
+}
+
+// writeCSV writes all rows of t to name, with the escape sequences of
+// strings resolved.
+func (i *Inspector) writeCSV(t *tableView, name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write(t.columns)
+	record := make([]string, len(t.columns))
+	for _, row := range t.rows {
+		for c, v := range row.cells {
+			record[c] = i.cellText(v)
+			if _, ok := v.(*stringValue); ok {
+				record[c] = unquoteRaw(record[c])
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// tableCommand prints the current array of objects as a table:
+//
+//	table [-sort col [-desc]] [-w width] [-n rows] [-offset n] [-csv file] [cols...]
+//
+// With -csv, all rows are written to the file instead, in the same order.
+func (i *Inspector) tableCommand(args string) error {
+	fs := newFlagSet("table")
+	sortColumn := fs.String("sort", "", "column to sort by")
+	descending := fs.Bool("desc", false, "sort in descending order")
+	width := fs.Int("w", 30, "maximum width of a cell")
+	rows := fs.Int("n", 50, "rows to show")
+	offset := fs.Int("offset", 0, "rows to skip")
+	csvFile := fs.String("csv", "", "write the table to this CSV file")
+	if err := fs.Parse(splitFields(args)); err != nil {
+		return err
+	}
+	arr, ok := i.current().value.(*arrayValue)
+	if !ok {
+		return fmt.Errorf("%s is not an array", displayLocation(i.current()))
+	}
+	t, err := i.newTableView(arr, fs.Args())
+	if err != nil {
+		return err
+	}
+	if *sortColumn != "" {
+		if err := i.sortTable(t, *sortColumn, *descending); err != nil {
+			return err
+		}
+	}
+	if *csvFile != "" {
+		if err := i.writeCSV(t, *csvFile); err != nil {
+			return err
+		}
+		metaColor.Fprintf(i.out, "Wrote %d rows to %s\n", len(t.rows), *csvFile)
+		return nil
+	}
+
+	start, end := *offset, len(t.rows)
+	if start < 0 || start > end {
+		return fmt.Errorf("offset %d is out of range", start)
+	}
+	if *rows > 0 && start+*rows < end {
+		end = start + *rows
+	}
+	w := tabwriter.NewWriter(i.out, 0, 4, 2, ' ', 0)
+	fmt.Fprint(w, "#")
+	for _, column := range t.columns {
+		fmt.Fprintf(w, "\t%s", truncateText(column, *width))
+	}
+	fmt.Fprintln(w)
+	for _, row := range t.rows[start:end] {
+		fmt.Fprint(w, strconv.Itoa(row.index))
+		for _, v := range row.cells {
+			fmt.Fprintf(w, "\t%s", truncateText(i.cellText(v), *width))
+		}
+		fmt.Fprintln(w)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if start > 0 || end < len(t.rows) {
+		metaColor.Fprintf(i.out, "-- rows %d-%d of %d --\n", start+1, end, len(t.rows))
+	}
+	return nil
+}
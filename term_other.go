@@ -0,0 +1,7 @@
+//go:build !unix
+
+package jsontools
+
+func terminalSize() (width, height int, ok bool) {
+	return 0, 0, false
+}
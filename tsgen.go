@@ -0,0 +1,205 @@
+package jsontools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsPropertyName returns name as a property name, quoted if it isn't an
+// identifier.
+func tsPropertyName(name string) string {
+	s := unquoteRaw(name)
+	if tsIdentifier.MatchString(s) {
+		return s
+	}
+	return quoteString(s)
+}
+
+// TypeScriptGenerator emits TypeScript interfaces, and optionally Zod
+// schemas, matching sample documents.
+type TypeScriptGenerator struct {
+	// TypeName is the name of the type for the document root.
+	TypeName string
+	// Zod also emits a Zod schema for every interface.
+	Zod bool
+
+	root *shape
+}
+
+func NewTypeScriptGenerator() *TypeScriptGenerator {
+	return &TypeScriptGenerator{
+		TypeName: "Root",
+		root:     newShape(),
+	}
+}
+
+// Add records the shape of a sample document.
+func (g *TypeScriptGenerator) Add(doc *decodeResult) {
+	g.root.observe(doc, doc.toplevel, 0)
+}
+
+type tsInterface struct {
+	name string
+	sh   *shape
+}
+
+// tsWriter holds the state of a single run of
+// TypeScriptGenerator.Generate.
+type tsWriter struct {
+	typeNamer
+	interfaces []*tsInterface
+	declared   map[*shape]string
+}
+
+func (w *tsWriter) declare(sh *shape, name, parent string) string {
+	t := w.typeName(name, parent)
+	w.interfaces = append(w.interfaces, &tsInterface{name: t, sh: sh})
+	w.declared[sh] = t
+	return t
+}
+
+// tsType returns the TypeScript type for values of shape sh, declaring
+// an interface named after name for objects.
+func (w *tsWriter) tsType(sh *shape, name, parent string) string {
+	var alts []string
+	for _, kind := range sh.kinds() {
+		switch kind {
+		case "object":
+			alts = append(alts, w.declare(sh, name, parent))
+		case "array":
+			elem := "unknown"
+			if sh.items.count > 0 {
+				elem = w.tsType(sh.items, singular(name), parent)
+			}
+			if strings.Contains(elem, " ") {
+				elem = "(" + elem + ")"
+			}
+			alts = append(alts, elem+"[]")
+		case "string":
+			alts = append(alts, "string")
+		case "integer", "number":
+			alts = append(alts, "number")
+		case "boolean":
+			alts = append(alts, "boolean")
+		}
+	}
+	if sh.nullable() {
+		alts = append(alts, "null")
+	}
+	if len(alts) == 0 {
+		return "unknown"
+	}
+	return strings.Join(alts, " | ")
+}
+
+// zodType returns the Zod schema expression for values of shape sh. The
+// interfaces must already be declared.
+func (w *tsWriter) zodType(sh *shape) string {
+	var alts []string
+	for _, kind := range sh.kinds() {
+		switch kind {
+		case "object":
+			alts = append(alts, w.declared[sh]+"Schema")
+		case "array":
+			elem := "z.unknown()"
+			if sh.items.count > 0 {
+				elem = w.zodType(sh.items)
+			}
+			alts = append(alts, "z.array("+elem+")")
+		case "string":
+			alts = append(alts, "z.string()")
+		case "integer":
+			alts = append(alts, "z.number().int()")
+		case "number":
+			alts = append(alts, "z.number()")
+		case "boolean":
+			alts = append(alts, "z.boolean()")
+		}
+	}
+	switch len(alts) {
+	case 0:
+		if sh.nullable() {
+			return "z.null()"
+		}
+		return "z.unknown()"
+	case 1:
+	default:
+		alts = []string{"z.union([" + strings.Join(alts, ", ") + "])"}
+	}
+	if sh.nullable() {
+		return alts[0] + ".nullable()"
+	}
+	return alts[0]
+}
+
+func (w *tsWriter) writeInterface(buf *bytes.Buffer, i *tsInterface) {
+	fmt.Fprintf(buf, "export interface %s {\n", i.name)
+	for _, member := range i.sh.propOrder {
+		child := i.sh.props[member]
+		optional := ""
+		if child.count < i.sh.types["object"] {
+			optional = "?"
+		}
+		fmt.Fprintf(buf, "  %s%s: %s;\n", tsPropertyName(member), optional,
+			w.tsType(child, exportedName(member), i.name))
+	}
+	buf.WriteString("}\n")
+}
+
+func (w *tsWriter) writeSchema(buf *bytes.Buffer, i *tsInterface) {
+	fmt.Fprintf(buf, "export const %sSchema = z.object({\n", i.name)
+	for _, member := range i.sh.propOrder {
+		child := i.sh.props[member]
+		t := w.zodType(child)
+		if child.count < i.sh.types["object"] {
+			t += ".optional()"
+		}
+		fmt.Fprintf(buf, "  %s: %s,\n", tsPropertyName(member), t)
+	}
+	buf.WriteString("});\n")
+}
+
+// Generate writes the interfaces, followed by the Zod schemas if
+// requested.
+func (g *TypeScriptGenerator) Generate(out io.Writer) error {
+	w := &tsWriter{
+		declared: make(map[*shape]string),
+	}
+	var buf bytes.Buffer
+	if g.Zod {
+		buf.WriteString("import { z } from \"zod\";\n\n")
+	}
+	rootIsObject := false
+	if kinds := g.root.kinds(); len(kinds) == 1 && kinds[0] == "object" && !g.root.nullable() {
+		rootIsObject = true
+		w.declare(g.root, g.TypeName, "")
+	} else {
+		w.typeName(g.TypeName, "")
+		fmt.Fprintf(&buf, "export type %s = %s;\n\n", g.TypeName, w.tsType(g.root, g.TypeName, g.TypeName))
+	}
+	for i := 0; i < len(w.interfaces); i++ {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		w.writeInterface(&buf, w.interfaces[i])
+	}
+
+	if g.Zod {
+		// Schemas refer to the schemas of nested interfaces, which were
+		// declared after their parents.
+		for i := len(w.interfaces) - 1; i >= 0; i-- {
+			buf.WriteString("\n")
+			w.writeSchema(&buf, w.interfaces[i])
+		}
+		if !rootIsObject {
+			fmt.Fprintf(&buf, "\nexport const %sSchema = %s;\n", g.TypeName, w.zodType(g.root))
+		}
+	}
+	_, err := buf.WriteTo(out)
+	return err
+}
@@ -0,0 +1,57 @@
+package jsontools
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeScriptGenerator(t *testing.T) {
+	g := NewTypeScriptGenerator()
+	g.Zod = true
+	g.Add(mustDecode(t, `{"id": 1, "name": "a", "value": 1, "items": [{"sku": "a"}], "content-type": "x"}`))
+	g.Add(mustDecode(t, `{"id": 2, "name": null, "value": "one", "items": [{"sku": "b", "qty": 2}, 3]}`))
+	var buf bytes.Buffer
+	assert.Nil(t, g.Generate(&buf))
+	assert.Equal(t, `import { z } from "zod";
+
+export interface Root {
+  id: number;
+  name: string | null;
+  value: string | number;
+  items: (Item | number)[];
+  "content-type"?: string;
+}
+
+export interface Item {
+  sku: string;
+  qty?: number;
+}
+
+export const ItemSchema = z.object({
+  sku: z.string(),
+  qty: z.number().int().optional(),
+});
+
+export const RootSchema = z.object({
+  id: z.number().int(),
+  name: z.string().nullable(),
+  value: z.union([z.string(), z.number().int()]),
+  items: z.array(z.union([ItemSchema, z.number().int()])),
+  "content-type": z.string().optional(),
+});
+`, buf.String())
+
+	g = NewTypeScriptGenerator()
+	g.TypeName = "Events"
+	g.Add(mustDecode(t, `[{"at": 1.5}]`))
+	buf.Reset()
+	assert.Nil(t, g.Generate(&buf))
+	assert.Equal(t, `export type Events = Event[];
+
+export interface Event {
+  at: number;
+}
+`, buf.String())
+}
@@ -0,0 +1,324 @@
+package jsontools
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// summary stops counting the distinct values of a member after this many.
+const maxSummaryValues = 1000
+
+// summary lists this many of the most frequent values of each member.
+const topSummaryValues = 3
+
+// newFlagSet returns a FlagSet for the options of an inspector command,
+// which reports errors instead of printing them.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// splitFields splits s at spaces, except within double quotes, which are
+// removed.
+func splitFields(s string) []string {
+	var fields []string
+	var field strings.Builder
+	quoted, hasQuotes := false, false
+	for n := 0; n < len(s); n++ {
+		switch c := s[n]; {
+		case c == '"':
+			quoted = !quoted
+			hasQuotes = true
+		case c == ' ' && !quoted:
+			if field.Len() > 0 || hasQuotes {
+				fields = append(fields, field.String())
+			}
+			field.Reset()
+			hasQuotes = false
+		default:
+			field.WriteByte(c)
+		}
+	}
+	if field.Len() > 0 || hasQuotes {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// parseFlags parses args into fs, allowing no other arguments.
+func parseFlags(fs *flag.FlagSet, args string) error {
+	if err := fs.Parse(splitFields(args)); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
+
+// showCommand prints the current value, "show -d 2 -w 10" expanding two
+// levels with at most ten members or elements each.
+func (i *Inspector) showCommand(args string) error {
+	fs := newFlagSet("show")
+	depth := fs.Int("d", 4, "levels to expand")
+	width := fs.Int("w", 32, "members or elements to show per container")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	err := i.printValue(i.current().value, *depth, *width, "", 0)
+	fmt.Fprintln(i.out)
+	return err
+}
+
+// typeLabel returns the type of v, with the size of containers, like
+// "array[3]".
+func typeLabel(v jsonValue) string {
+	switch value := v.(type) {
+	case *objectValue:
+		return fmt.Sprintf("object{%d}", len(value.keys))
+	case *arrayValue:
+		return fmt.Sprintf("array[%d]", len(value.elems))
+	case *lazyValue:
+		if value.entry.kind == '{' {
+			return "object"
+		}
+		return "array"
+	}
+	return typeName(v)
+}
+
+// typeName returns the JSON type of v.
+func typeName(v jsonValue) string {
+	switch value := v.(type) {
+	case *objectValue:
+		return "object"
+	case *arrayValue:
+		return "array"
+	case *stringValue:
+		return "string"
+	case *numberValue:
+		return "number"
+	case *literalValue:
+		if value.value == Null {
+			return "null"
+		}
+		return "boolean"
+	case *lazyValue:
+		return typeLabel(v)
+	}
+	return "unknown"
+}
+
+// treeCommand prints the structure below the current value, like
+// tree(1).
+func (i *Inspector) treeCommand(args string) error {
+	fs := newFlagSet("tree")
+	depth := fs.Int("d", 3, "levels to expand")
+	width := fs.Int("w", 32, "members or elements to show per container")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	cur := i.current()
+	fmt.Fprintf(i.out, "%s: %s\n", displayLocation(cur), typeLabel(cur.value))
+	return i.printTree(cur.value, *depth, *width, "")
+}
+
+func (i *Inspector) printTree(v jsonValue, depth int, width int, prefix string) error {
+	if depth <= 0 {
+		return nil
+	}
+	v, err := i.resolve(v)
+	if err != nil {
+		return err
+	}
+	var names []string
+	var children []jsonValue
+	switch value := v.(type) {
+	case *objectValue:
+		for _, id := range value.keys {
+			names = append(names, i.idToStr(id))
+			children = append(children, value.props[id])
+		}
+	case *arrayValue:
+		for n, e := range value.elems {
+			names = append(names, fmt.Sprintf("[%d]", n))
+			children = append(children, e)
+		}
+	}
+	more := 0
+	if len(children) > width {
+		more = len(children) - width
+		names, children = names[:width], children[:width]
+	}
+	for n, child := range children {
+		branch, next := "├── ", "│   "
+		if n == len(children)-1 && more == 0 {
+			branch, next = "└── ", "    "
+		}
+		if depth > 1 {
+			if child, err = i.resolve(child); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(i.out, "%s%s", prefix, branch)
+		memberColor.Fprintf(i.out, "%s", names[n])
+		fmt.Fprintf(i.out, ": %s\n", typeLabel(child))
+		if err := i.printTree(child, depth-1, width, prefix+next); err != nil {
+			return err
+		}
+	}
+	if more > 0 {
+		fmt.Fprintf(i.out, "%s└── ", prefix)
+		metaColor.Fprintf(i.out, "... %d more\n", more)
+	}
+	return nil
+}
+
+// fieldSummary describes the values a member takes across the elements of
+// an array.
+type fieldSummary struct {
+	name  string
+	count int
+	types map[string]int
+	// values counts the distinct primitive values, until there are too
+	// many of them, when it is set to nil.
+	values   map[string]int
+	numbers  int
+	min, max float64
+}
+
+func (f *fieldSummary) add(i *Inspector, v jsonValue) {
+	f.count++
+	f.types[typeName(v)]++
+	switch value := v.(type) {
+	case *objectValue, *arrayValue, *lazyValue:
+		return
+	case *numberValue:
+		if f.numbers == 0 || value.value < f.min {
+			f.min = value.value
+		}
+		if f.numbers == 0 || value.value > f.max {
+			f.max = value.value
+		}
+		f.numbers++
+	}
+	if f.values != nil {
+		s := truncateText(i.json.encodeValue(v), maxPreviewLength)
+		f.values[s]++
+		if len(f.values) > maxSummaryValues {
+			f.values = nil
+		}
+	}
+}
+
+// byCount returns the keys of counts, most frequent first.
+func byCount(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if counts[keys[a]] != counts[keys[b]] {
+			return counts[keys[a]] > counts[keys[b]]
+		}
+		return keys[a] < keys[b]
+	})
+	return keys
+}
+
+func (f *fieldSummary) typesString() string {
+	types := byCount(f.types)
+	if len(types) == 1 {
+		return types[0]
+	}
+	for n, t := range types {
+		types[n] = t + " " + strconv.Itoa(f.types[t])
+	}
+	return strings.Join(types, ", ")
+}
+
+func (f *fieldSummary) valuesString() string {
+	var parts []string
+	if f.values == nil {
+		parts = append(parts, fmt.Sprintf("over %d distinct", maxSummaryValues))
+	} else if len(f.values) > 0 {
+		parts = append(parts, fmt.Sprintf("%d distinct", len(f.values)))
+		var top []string
+		for n, v := range byCount(f.values) {
+			if n == topSummaryValues {
+				break
+			}
+			top = append(top, fmt.Sprintf("%s (%d)", v, f.values[v]))
+		}
+		parts = append(parts, strings.Join(top, ", "))
+	}
+	if f.numbers > 0 {
+		parts = append(parts, fmt.Sprintf("range %s to %s", formatNumber(f.min), formatNumber(f.max)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// summarize returns the members found in the elements of arr, in the
+// order they first appear. Elements which aren't objects are described
+// by a field named "(element)".
+func (i *Inspector) summarize(arr *arrayValue) ([]*fieldSummary, error) {
+	var fields []*fieldSummary
+	byName := make(map[string]*fieldSummary)
+	field := func(name string) *fieldSummary {
+		f := byName[name]
+		if f == nil {
+			f = &fieldSummary{
+				name:   name,
+				types:  make(map[string]int),
+				values: make(map[string]int),
+			}
+			byName[name] = f
+			fields = append(fields, f)
+		}
+		return f
+	}
+	for _, e := range arr.elems {
+		e, err := i.resolve(e)
+		if err != nil {
+			return nil, err
+		}
+		obj, ok := e.(*objectValue)
+		if !ok {
+			field(elementColumn).add(i, e)
+			continue
+		}
+		for _, id := range obj.keys {
+			field(i.idToStr(id)).add(i, obj.props[id])
+		}
+	}
+	return fields, nil
+}
+
+// summaryCommand prints how often each member occurs across the elements
+// of the current array, with its types and most frequent values.
+func (i *Inspector) summaryCommand(args string) error {
+	if err := parseFlags(newFlagSet("summary"), args); err != nil {
+		return err
+	}
+	arr, ok := i.current().value.(*arrayValue)
+	if !ok {
+		return fmt.Errorf("%s is not an array", displayLocation(i.current()))
+	}
+	fields, err := i.summarize(arr)
+	if err != nil {
+		return err
+	}
+	metaColor.Fprintf(i.out, "%d elements\n", len(arr.elems))
+	w := tabwriter.NewWriter(i.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "MEMBER\tPRESENT\tTYPES\tVALUES\n")
+	for _, f := range fields {
+		fmt.Fprintf(w, "%s\t%d (%d%%)\t%s\t%s\n", f.name, f.count, f.count*100/len(arr.elems), f.typesString(), f.valuesString())
+	}
+	return w.Flush()
+}